@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/store/mysql"
+	"github.com/spacycoder/db_mysql/pkg/store/shard"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// newRootCommand returns the command tree. Every flag can also be set with an
+// environment variable or in a config file, see applySettings.
+func newRootCommand() *cobra.Command {
+	config := &Config{}
+	var configPath, shards, oldShards, replicas string
+
+	root := &cobra.Command{
+		Use:   "db_mysql",
+		Short: "Load the Geolife dataset into a database and run the exercises on it",
+		Long: `Load the Geolife dataset into a database and run the exercises on it.
+
+Every flag can also be set with the environment variable STRAVA_<FLAG>, for
+example STRAVA_BATCH_ROWS for --batch-rows, or in the YAML or TOML file of
+--config (or STRAVA_CONFIG) under the name of the flag. The command line takes
+precedence over the environment, which takes precedence over the config file.
+The MySQL password is only read from STRAVA_PASSWORD or the password key of the
+config file.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			password, err := applySettings(cmd, configPath)
+			if err != nil {
+				return err
+			}
+			if password != nil {
+				config.Password = *password
+			}
+			config.Shards = splitList(shards)
+			config.OldShards = splitList(oldShards)
+			config.Replicas = splitList(replicas)
+			return validateConfig(cmd, config)
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&configPath, "config", "", "YAML (.yaml, .yml) or TOML (.toml) file with settings, keyed by flag name")
+	flags.StringVar(&config.Driver, "driver", driverMySQL, "database to use: mysql, sqlite, postgres, mongo, or memory to load --dataset into memory when connecting")
+	flags.StringVar(&config.DSN, "dsn", "", "data source name: the database file for sqlite, a connection string for postgres or a URI for mongo (all required) or a go-sql-driver DSN for mysql, which replaces --host, --port, --db-user and --database")
+	flags.StringVar(&config.Host, "host", "localhost", "host of the MySQL server")
+	flags.IntVar(&config.Port, "port", 3306, "port of the MySQL server")
+	flags.StringVar(&config.User, "db-user", "", "user of the MySQL server, whose password is set with STRAVA_PASSWORD or in the config file")
+	flags.StringVar(&config.Database, "database", "strava", "database on the MySQL server")
+	flags.StringVar(&config.DatasetRoot, "dataset", "./dataset", "folder of the Geolife dataset, with labeled_ids.txt and the Data folder")
+	flags.IntVar(&config.WorkerCount, "workers", runtime.NumCPU()*2, "number of goroutines parsing the dataset")
+	flags.IntVar(&config.MaxOpenConns, "max-open-conns", 10, "maximum number of open connections to each database server")
+	flags.IntVar(&config.MaxIdleConns, "max-idle-conns", 10, "maximum number of idle connections to each database server")
+	flags.DurationVar(&config.ConnMaxLifetime, "conn-max-lifetime", 3*time.Minute, "maximum time a connection to a database server is reused")
+	flags.StringVar(&shards, "shards", "", "comma separated go-sql-driver DSNs of the MySQL servers the users are spread over, each optionally prefixed with a name and =")
+	flags.StringVar(&oldShards, "old-shards", "", "the --shards the users were spread over before they are rebalanced to --shards")
+	flags.StringVar(&config.Routes, "routes", "", "name of the shard of --shards or --old-shards that keeps the routing table of the users moving between shards (default "+shard.Name(0)+")")
+	flags.StringVar(&replicas, "replicas", "", "comma separated go-sql-driver DSNs of MySQL read replicas the exercise queries are sent to")
+	flags.DurationVar(&config.MaxReplicaLag, "max-replica-lag", 0, "read from the primary while a replica is further behind than this, 0 to not check the lag")
+
+	root.AddCommand(
+		newLoadCommand(config),
+		newExercisesCommand(config),
+		newQueryCommand(config),
+		newValidateCommand(config),
+		newMigrateCommand(config),
+		newDropCommand(config),
+		newRebalanceCommand(config),
+		newShellCommand(config),
+	)
+	return root
+}
+
+func newLoadCommand(config *Config) *cobra.Command {
+	var onError string
+	cmd := &cobra.Command{
+		Use:   "load",
+		Short: "Load the dataset, skipping the files that are already loaded",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if onError != "fail" && onError != "continue" {
+				return fmt.Errorf("invalid --on-error value: %s", onError)
+			}
+			config.FailFast = onError == "fail"
+
+			info, err := os.Stat(config.DatasetRoot)
+			if os.IsNotExist(err) || (err == nil && !info.IsDir()) {
+				return fmt.Errorf("dataset folder %s not found", config.DatasetRoot)
+			}
+			if err != nil {
+				return err
+			}
+
+			return withStore(config, func(st store.Store) error {
+				if err := runMigrate(st.Migrator(), "up"); err != nil {
+					return err
+				}
+				if err := st.Prepare(); err != nil {
+					return err
+				}
+				return loadDataset(config, st)
+			})
+		},
+	}
+
+	defaults := defaultConfig()
+	flags := cmd.Flags()
+	flags.StringVar(&onError, "on-error", "fail", "what load does when a user or file fails: fail,continue")
+	flags.StringVar(&config.ReportPath, "report", "load_report.json", "file the load report is written to as JSON, empty to disable")
+	flags.IntVar(&config.WriterCount, "writers", defaults.WriterCount, "number of goroutines writing trackpoints to the database")
+	flags.IntVar(&config.BatchBytes, "batch-bytes", defaults.BatchBytes, "maximum estimated size in bytes of the trackpoints written in one transaction")
+	flags.IntVar(&config.QueueSize, "queue", defaults.QueueSize, "number of users and files that may wait between two load stages")
+	addParseFlags(flags, config)
+	addWriteFlags(flags, config)
+	return cmd
+}
+
+func newExercisesCommand(config *Config) *cobra.Command {
+	var tasks []int
+	var list bool
+	var values func() map[string]string
+	var out *output
+	cmd := &cobra.Command{
+		Use:   "exercises",
+		Short: "Run the tasks of the assignment, all of them unless --task is given",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if list {
+				listExercises()
+				return nil
+			}
+			if _, _, err := exerciseArgs(tasks, values()); err != nil {
+				return err
+			}
+			if err := out.check(); err != nil {
+				return err
+			}
+			return withStore(config, func(st store.Store) error {
+				if err := st.Prepare(); err != nil {
+					return err
+				}
+				return out.render(st, func(r renderer) error {
+					return runExercises(st, tasks, values(), r)
+				})
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntSliceVar(&tasks, "task", nil, "number of a task to run, can be repeated")
+	flags.BoolVar(&list, "list", false, "list the tasks with their parameters instead of running them")
+	values = addTaskFlags(flags)
+	out = addOutputFlags(flags)
+	flags.StringVar(&out.report, "report", "", "HTML file to also write the results to, with charts of tasks 3, 5, 6 and 8 and a map of task 10")
+	return cmd
+}
+
+func newQueryCommand(config *Config) *cobra.Command {
+	var values func() map[string]string
+	var out *output
+	cmd := &cobra.Command{
+		Use:   "query <task>...",
+		Short: "Run the tasks with the given numbers",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tasks := make([]int, len(args))
+			for i, arg := range args {
+				n, err := strconv.Atoi(arg)
+				if err != nil {
+					return fmt.Errorf("invalid task number: %s", arg)
+				}
+				tasks[i] = n
+			}
+			if _, _, err := exerciseArgs(tasks, values()); err != nil {
+				return err
+			}
+			if err := out.check(); err != nil {
+				return err
+			}
+
+			return withStore(config, func(st store.Store) error {
+				if err := st.Prepare(); err != nil {
+					return err
+				}
+				return out.render(st, func(r renderer) error {
+					return runExercises(st, tasks, values(), r)
+				})
+			})
+		},
+	}
+	values = addTaskFlags(cmd.Flags())
+	out = addOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func newShellCommand(config *Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "shell",
+		Short: "Explore the loaded dataset with an interactive prompt",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runShell(config)
+		},
+	}
+}
+
+func newValidateCommand(config *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the dataset with the rules of the loader without a database",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validateDataset(config)
+		},
+	}
+	addParseFlags(cmd.Flags(), config)
+	return cmd
+}
+
+func newMigrateCommand(config *Config) *cobra.Command {
+	return &cobra.Command{
+		Use:       "migrate up|down|status",
+		Short:     "Apply the pending migrations, roll back the latest one or list them",
+		Args:      cobra.ExactValidArgs(1),
+		ValidArgs: []string{"up", "down", "status"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withStore(config, func(st store.Store) error {
+				return runMigrate(st.Migrator(), args[0])
+			})
+		},
+	}
+}
+
+func newDropCommand(config *Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "drop",
+		Short: "Roll back every migration, which drops all tables",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withStore(config, func(st store.Store) error {
+				rolledBack, err := st.Migrator().Reset()
+				for _, m := range rolledBack {
+					fmt.Printf("Rolled back %d %s\n", m.Version, m.Name)
+				}
+				return err
+			})
+		},
+	}
+}
+
+func newRebalanceCommand(config *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rebalance",
+		Short: "Move the users from the --old-shards to the shards --shards assigns them to",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withStore(config, func(st store.Store) error {
+				sharded, ok := st.(*shard.Store)
+				if !ok {
+					return errors.New("rebalance requires --shards")
+				}
+
+				if err := runMigrate(st.Migrator(), "up"); err != nil {
+					return err
+				}
+				if err := st.Prepare(); err != nil {
+					return err
+				}
+				return rebalance(config, sharded)
+			})
+		},
+	}
+	addWriteFlags(cmd.Flags(), config)
+	return cmd
+}
+
+// addParseFlags adds the flags of the rules the dataset is parsed with.
+func addParseFlags(flags *pflag.FlagSet, config *Config) {
+	defaults := defaultConfig()
+	flags.StringVar(&config.MatchMode, "match", defaults.MatchMode, "how trackpoints are matched to labels: contained,strict")
+	flags.StringVar(&config.SizePolicy, "size-policy", defaults.SizePolicy, "what load does with trajectories over --max-points trackpoints: skip,truncate")
+	flags.IntVar(&config.MaxPoints, "max-points", defaults.MaxPoints, "maximum number of trackpoints per trajectory")
+}
+
+// addWriteFlags adds the flags of how trackpoints are written.
+func addWriteFlags(flags *pflag.FlagSet, config *Config) {
+	defaults := defaultConfig()
+	flags.StringVar(&config.Strategy, "strategy", defaults.Strategy, "how trackpoints are written: insert (multi-row INSERT), loaddata (LOAD DATA LOCAL INFILE)")
+	flags.IntVar(&config.BatchRows, "batch-rows", defaults.BatchRows, "maximum number of trackpoints written in one transaction")
+}
+
+// validateConnection checks the settings of the connection.
+func validateConnection(config *Config) error {
+	if config.Driver != driverMySQL && config.Driver != driverSQLite && config.Driver != driverPostgres && config.Driver != driverMongo && config.Driver != driverMemory {
+		return fmt.Errorf("invalid --driver value: %s", config.Driver)
+	}
+	if config.Driver != driverMySQL && config.Driver != driverMemory && config.DSN == "" {
+		return fmt.Errorf("--driver %s requires --dsn", config.Driver)
+	}
+	if len(config.Shards) > 0 && (config.Driver != driverMySQL || config.DSN != "") {
+		return errors.New("--shards is only supported by --driver mysql without --dsn")
+	}
+	if len(config.OldShards) > 0 && len(config.Shards) == 0 {
+		return errors.New("--old-shards requires --shards")
+	}
+	if config.Routes != "" && len(config.Shards) == 0 {
+		return errors.New("--routes requires --shards")
+	}
+	if len(config.Replicas) > 0 && (config.Driver != driverMySQL || len(config.Shards) > 0) {
+		return errors.New("--replicas is only supported by --driver mysql without --shards")
+	}
+	if config.MaxReplicaLag < 0 {
+		return fmt.Errorf("invalid --max-replica-lag value: %s", config.MaxReplicaLag)
+	}
+	if config.Port <= 0 || config.Port > 65535 {
+		return fmt.Errorf("invalid --port value: %d", config.Port)
+	}
+	if config.MaxOpenConns < 0 || config.MaxIdleConns < 0 || config.ConnMaxLifetime < 0 {
+		return errors.New("invalid --max-open-conns, --max-idle-conns or --conn-max-lifetime value")
+	}
+	return nil
+}
+
+// validateConfig checks the settings of the connection and the settings cmd
+// has flags for.
+func validateConfig(cmd *cobra.Command, config *Config) error {
+	if err := validateConnection(config); err != nil {
+		return err
+	}
+	if config.WorkerCount <= 0 {
+		return fmt.Errorf("invalid --workers value: %d", config.WorkerCount)
+	}
+	if config.Driver != driverMySQL && config.Strategy == mysql.StrategyLoadData {
+		return errors.New("--strategy loaddata is only supported by --driver mysql")
+	}
+	if hasFlag(cmd, "match") && config.MatchMode != matchContained && config.MatchMode != matchStrict {
+		return fmt.Errorf("invalid --match value: %s", config.MatchMode)
+	}
+	if hasFlag(cmd, "size-policy") && config.SizePolicy != policySkip && config.SizePolicy != policyTruncate {
+		return fmt.Errorf("invalid --size-policy value: %s", config.SizePolicy)
+	}
+	if hasFlag(cmd, "strategy") && config.Strategy != mysql.StrategyInsert && config.Strategy != mysql.StrategyLoadData {
+		return fmt.Errorf("invalid --strategy value: %s", config.Strategy)
+	}
+	if hasFlag(cmd, "max-points") && config.MaxPoints <= 0 {
+		return fmt.Errorf("invalid --max-points value: %d", config.MaxPoints)
+	}
+	if hasFlag(cmd, "batch-rows") && config.BatchRows <= 0 {
+		return fmt.Errorf("invalid --batch-rows value: %d", config.BatchRows)
+	}
+	if hasFlag(cmd, "writers") && (config.WriterCount <= 0 || config.BatchBytes <= 0 || config.QueueSize < 0) {
+		return errors.New("invalid --writers, --batch-bytes or --queue value")
+	}
+	return nil
+}
+
+// hasFlag reports whether cmd has a flag called name.
+func hasFlag(cmd *cobra.Command, name string) bool {
+	return cmd.Flags().Lookup(name) != nil
+}
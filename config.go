@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix is the prefix of the environment variables that set flags.
+const envPrefix = "STRAVA_"
+
+// passwordKey is the setting of the MySQL password, which has no flag so that
+// it does not end up in the shell history.
+const passwordKey = "password"
+
+// applySettings sets the flags of cmd that were not given on the command line
+// from the environment and the config file. A flag is taken from the command
+// line first, then from the environment variable STRAVA_<NAME> (STRAVA_BATCH_ROWS
+// for --batch-rows), then from the key named like the flag in the config file,
+// and keeps its default otherwise. The config file is --config or
+// STRAVA_CONFIG. It returns the password from STRAVA_PASSWORD or the config
+// file, nil if neither sets it.
+func applySettings(cmd *cobra.Command, configPath string) (*string, error) {
+	if configPath == "" {
+		configPath = os.Getenv(envName("config"))
+	}
+
+	var file map[string]string
+	if configPath != "" {
+		var err error
+		file, err = readSettings(configPath)
+		if err != nil {
+			return nil, err
+		}
+		known := knownSettings(cmd.Root())
+		for key := range file {
+			if !known[key] {
+				return nil, fmt.Errorf("%s: unknown setting %s", configPath, key)
+			}
+		}
+	}
+
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "config" {
+			return
+		}
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("%s: %w", envName(f.Name), setErr)
+			}
+			return
+		}
+		if value, ok := file[f.Name]; ok {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("%s: %s: %w", configPath, f.Name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if password, ok := os.LookupEnv(envName(passwordKey)); ok {
+		return &password, nil
+	}
+	if password, ok := file[passwordKey]; ok {
+		return &password, nil
+	}
+	return nil, nil
+}
+
+// envName returns the environment variable of a setting.
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+}
+
+// readSettings reads a YAML or TOML config file, depending on its extension,
+// and returns its settings as flag values. Lists are joined with commas.
+func readSettings(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		_, err = toml.Decode(string(data), &raw)
+	default:
+		return nil, fmt.Errorf("%s: config files have to end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	settings := make(map[string]string)
+	for key, value := range raw {
+		s, err := settingValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+		settings[key] = s
+	}
+	return settings, nil
+}
+
+func settingValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := settingValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[interface{}]interface{}, map[string]interface{}:
+		return "", fmt.Errorf("expected a value or a list, got a table")
+	case nil:
+		return "", nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// knownSettings returns the names of the flags of every command below root and
+// the password.
+func knownSettings(root *cobra.Command) map[string]bool {
+	known := map[string]bool{passwordKey: true}
+	commands := []*cobra.Command{root}
+	for len(commands) > 0 {
+		cmd := commands[0]
+		commands = append(commands[1:], cmd.Commands()...)
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			known[f.Name] = true
+		})
+		cmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+			known[f.Name] = true
+		})
+	}
+	delete(known, "config")
+	delete(known, "help")
+	return known
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spf13/pflag"
+)
+
+// Kinds of the values of task parameters.
+const (
+	paramString = "string"
+	paramInt    = "int"
+	paramCount  = "count"
+	paramFloat  = "float"
+)
+
+// taskParam is a named parameter of a task with its default value.
+type taskParam struct {
+	name  string
+	kind  string
+	value string
+	usage string
+}
+
+// taskArgs are the values of the parameters of a task by name, checked against
+// the kinds of the parameters.
+type taskArgs map[string]string
+
+func (a taskArgs) str(name string) string {
+	return a[name]
+}
+
+func (a taskArgs) int(name string) int {
+	n, _ := strconv.Atoi(a[name])
+	return n
+}
+
+func (a taskArgs) float(name string) float64 {
+	f, _ := strconv.ParseFloat(a[name], 64)
+	return f
+}
+
+// limitParam is the number of users a task lists.
+var limitParam = taskParam{name: "limit", kind: paramCount, value: "20", usage: "number of users"}
+
+// exercise is a task of the assignment.
+type exercise struct {
+	description string
+	params      []taskParam
+	run         func(st store.Store, args taskArgs) (interface{}, error)
+}
+
+// Center of Beijing, around which task 10 searches by default.
+const (
+	beijingLat = "39.916"
+	beijingLon = "116.397"
+)
+
+// exercises are the tasks of the assignment, task n at index n-1.
+var exercises = []exercise{
+	{
+		description: "number of users, activities and trackpoints",
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task1(st.Activities(), st.Users(), st.Trackpoints())
+		},
+	},
+	{
+		description: "average number of activities per user",
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task2(st.Activities())
+		},
+	},
+	{
+		description: "users with the most activities",
+		params:      []taskParam{limitParam},
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task3(st.Activities(), args.int("limit"))
+		},
+	},
+	{
+		description: "users that have used a transportation mode",
+		params:      []taskParam{{name: "mode", kind: paramString, value: "Taxi", usage: "transportation mode"}},
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task4(st.Users(), args.str("mode"))
+		},
+	},
+	{
+		description: "number of activities of each transportation mode",
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task5(st.Activities())
+		},
+	},
+	{
+		description: "year with the most activities and whether it has the most hours",
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task6(st.Activities())
+		},
+	},
+	{
+		description: "kilometers a user covered with a transportation mode in a year",
+		params: []taskParam{
+			{name: "user", kind: paramString, value: "112", usage: "user id"},
+			{name: "mode", kind: paramString, value: "walk", usage: "transportation mode"},
+			{name: "year", kind: paramInt, value: "2008", usage: "year the activities started in"},
+		},
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task7(st.Activities(), args.str("user"), args.str("mode"), args.int("year"))
+		},
+	},
+	{
+		description: "users that gained the most altitude walking",
+		params:      []taskParam{limitParam},
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task8(st.Users(), args.int("limit"))
+		},
+	},
+	{
+		description: "users with activities with gaps of 5 minutes or more",
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task9(st.Users())
+		},
+	},
+	{
+		description: "users with a trackpoint around a position",
+		params: []taskParam{
+			{name: "lat", kind: paramFloat, value: beijingLat, usage: "latitude of the position"},
+			{name: "lon", kind: paramFloat, value: beijingLon, usage: "longitude of the position"},
+		},
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task10(st.Users(), args.float("lat"), args.float("lon"))
+		},
+	},
+	{
+		description: "most used transportation mode of every user",
+		run: func(st store.Store, args taskArgs) (interface{}, error) {
+			return task11(st.Activities())
+		},
+	},
+}
+
+// runExercises runs the tasks with the given numbers, all of them if none are
+// given, and adds their results to r. values overrides the defaults of the
+// parameters of the tasks by name, and every value has to be a parameter of one
+// of the tasks.
+func runExercises(st store.Store, tasks []int, values map[string]string, r renderer) error {
+	tasks, args, err := exerciseArgs(tasks, values)
+	if err != nil {
+		return err
+	}
+
+	for i, n := range tasks {
+		rows, err := exercises[n-1].run(st, args[i])
+		if err != nil {
+			return err
+		}
+		result := taskResult{Task: n, Description: exercises[n-1].description, Parameters: args[i], Rows: rows}
+		if err := r.add(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exerciseArgs checks the task numbers and values of runExercises, and returns
+// the tasks to run with their arguments.
+func exerciseArgs(tasks []int, values map[string]string) ([]int, []taskArgs, error) {
+	if len(tasks) == 0 {
+		for i := range exercises {
+			tasks = append(tasks, i+1)
+		}
+	}
+
+	for _, n := range tasks {
+		if n < 1 || n > len(exercises) {
+			return nil, nil, fmt.Errorf("no task %d, tasks are numbered 1 to %d", n, len(exercises))
+		}
+	}
+
+	used := make(map[string]bool)
+	args := make([]taskArgs, len(tasks))
+	for i, n := range tasks {
+		args[i] = make(taskArgs)
+		for _, param := range exercises[n-1].params {
+			value, ok := values[param.name]
+			if !ok {
+				value = param.value
+			}
+			if err := checkParam(param, value); err != nil {
+				return nil, nil, err
+			}
+			args[i][param.name] = value
+			if ok {
+				used[param.name] = true
+			}
+		}
+	}
+	for name := range values {
+		if !used[name] {
+			return nil, nil, fmt.Errorf("--%s is not a parameter of the tasks that are run", name)
+		}
+	}
+	return tasks, args, nil
+}
+
+func checkParam(param taskParam, value string) error {
+	var err error
+	switch param.kind {
+	case paramInt:
+		_, err = strconv.Atoi(value)
+	case paramCount:
+		var n int
+		if n, err = strconv.Atoi(value); err == nil && n <= 0 {
+			err = errors.New("not positive")
+		}
+	case paramFloat:
+		_, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid --%s value: %s", param.name, value)
+	}
+	return nil
+}
+
+// listExercises prints the tasks with their parameters and defaults.
+func listExercises() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Task", "Description", "Parameters"})
+	table.SetAutoWrapText(false)
+	for i, e := range exercises {
+		var params []string
+		for _, param := range e.params {
+			params = append(params, "--"+param.name+" "+param.value)
+		}
+		table.Append([]string{strconv.Itoa(i + 1), e.description, strings.Join(params, " ")})
+	}
+	table.Render()
+}
+
+// addTaskFlags adds a flag for every parameter of the tasks, and returns a
+// function that returns the values of the flags that are set.
+func addTaskFlags(flags *pflag.FlagSet) func() map[string]string {
+	var names []string
+	defaults := make(map[string][]string)
+	usages := make(map[string]string)
+	for i, e := range exercises {
+		for _, param := range e.params {
+			if _, ok := usages[param.name]; !ok {
+				names = append(names, param.name)
+				usages[param.name] = param.usage
+			}
+			defaults[param.name] = append(defaults[param.name], fmt.Sprintf("task %d: %s", i+1, param.value))
+		}
+	}
+	sort.Strings(names)
+
+	values := make(map[string]*string)
+	for _, name := range names {
+		usage := fmt.Sprintf("%s (default %s)", usages[name], strings.Join(defaults[name], ", "))
+		values[name] = flags.String(name, "", usage)
+	}
+
+	return func() map[string]string {
+		set := make(map[string]string)
+		for name, value := range values {
+			if *value != "" {
+				set[name] = *value
+			}
+		}
+		return set
+	}
+}
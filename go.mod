@@ -3,10 +3,14 @@ module github.com/spacycoder/db_mysql
 go 1.15
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/c-bata/go-prompt v0.2.5
 	github.com/go-sql-driver/mysql v1.5.0
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/olekukonko/tablewriter v0.0.4
-	github.com/spf13/cobra v1.0.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.0.0-20200923182605-d9f96fdee20d // indirect
+	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.5
+	go.mongodb.org/mongo-driver v1.17.6
+	gopkg.in/yaml.v2 v2.4.0
 )
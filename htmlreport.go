@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+const (
+	// mapBox is the distance in degrees around the position of task 10 within
+	// which it finds the users, and the map of the report their trackpoints.
+	mapBox = 0.001
+	// mapSpan is the distance in degrees around the position that the map
+	// shows, a margin around the box.
+	mapSpan = 0.0015
+	// maxMapPoints is the number of trackpoints the map draws at most.
+	maxMapPoints = 10000
+)
+
+// metersPerDegree is the length of a degree of latitude.
+const metersPerDegree = 111320
+
+// colors of the bars and of the users on the map.
+var colors = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac"}
+
+// htmlReport is a renderer that writes one HTML file with a table of every
+// result and charts of tasks 3, 5, 6 and 8 and a map of task 10, all inline, so
+// that the file can be opened offline. Task 6 and 10 read what their charts
+// show from st.
+type htmlReport struct {
+	st       store.Store
+	w        io.Writer
+	sections []reportSection
+}
+
+// reportSection is the part of the report about one task.
+type reportSection struct {
+	Title      string
+	Parameters string
+	Columns    []string
+	Rows       [][]string
+	Charts     []template.HTML
+}
+
+func newHTMLReport(st store.Store, w io.Writer) *htmlReport {
+	return &htmlReport{st: st, w: w}
+}
+
+func (r *htmlReport) add(result taskResult) error {
+	t, rows := resultRows(result.Rows)
+	section := reportSection{
+		Title:   fmt.Sprintf("Task %d: %s", result.Task, result.Description),
+		Columns: columns(t),
+	}
+	var params []string
+	for _, name := range sortedKeys(result.Parameters) {
+		params = append(params, "--"+name+" "+result.Parameters[name])
+	}
+	section.Parameters = strings.Join(params, " ")
+	for _, row := range rows {
+		section.Rows = append(section.Rows, cells(row, 2))
+	}
+
+	charts, err := r.charts(result)
+	if err != nil {
+		return err
+	}
+	section.Charts = charts
+	r.sections = append(r.sections, section)
+	return nil
+}
+
+// charts draws the charts of the tasks that have them.
+func (r *htmlReport) charts(result taskResult) ([]template.HTML, error) {
+	switch rows := result.Rows.(type) {
+	case []activity.UserCount:
+		bars := make([]bar, len(rows))
+		for i, row := range rows {
+			bars[i] = bar{label: row.UserID, value: float64(row.Activities)}
+		}
+		return []template.HTML{barChart("Activities per user", bars, 0)}, nil
+
+	case []activity.ModeCount:
+		bars := make([]bar, len(rows))
+		for i, row := range rows {
+			bars[i] = bar{label: row.TransportationMode, value: float64(row.Activities)}
+			if row.TransportationMode == "" {
+				bars[i].label = "(none)"
+			}
+		}
+		return []template.HTML{barChart("Activities per transportation mode", bars, 0)}, nil
+
+	case yearSummary:
+		counts, err := r.st.Activities().GetActivityCountsByYear()
+		if err != nil {
+			return nil, err
+		}
+		hours, err := r.st.Activities().GetHoursByYear()
+		if err != nil {
+			return nil, err
+		}
+		return []template.HTML{
+			barChart("Activities per year", yearBars(counts), 0),
+			barChart("Hours per year", yearBars(hours), 0),
+		}, nil
+
+	case []user.UserWithAltitude:
+		bars := make([]bar, len(rows))
+		for i, row := range rows {
+			bars[i] = bar{label: row.UserID, value: row.GainedAltitude}
+		}
+		return []template.HTML{barChart("Altitude gained walking (m)", bars, 1)}, nil
+
+	case []userRow:
+		if result.Task != 10 {
+			return nil, nil
+		}
+		lat, lon := result.Parameters.float("lat"), result.Parameters.float("lon")
+		points, err := r.trackpointsAround(rows, lat, lon)
+		if err != nil {
+			return nil, err
+		}
+		return []template.HTML{trackpointMap(rows, points, lat, lon)}, nil
+	}
+	return nil, nil
+}
+
+// trackpointsAround returns the trackpoints of the users that are at most
+// mapBox degrees from lat and lon, which made task 10 find them, by user. The
+// users share maxMapPoints: each may have an equal part of what the users
+// before it left, so that the first ones cannot take all of them.
+func (r *htmlReport) trackpointsAround(users []userRow, lat, lon float64) ([][]trackpoint.Trackpoint, error) {
+	points := make([][]trackpoint.Trackpoint, len(users))
+	left := maxMapPoints
+	for i, usr := range users {
+		limit := left / (len(users) - i)
+		if limit == 0 {
+			continue
+		}
+		found, err := r.st.Trackpoints().GetAround(usr.UserID, lat, lon, mapBox, limit)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = found
+		left -= len(found)
+	}
+	return points, nil
+}
+
+func (r *htmlReport) close() error {
+	return reportTemplate.Execute(r.w, struct {
+		Generated string
+		Sections  []reportSection
+	}{
+		Generated: time.Now().Format("2006-01-02 15:04:05"),
+		Sections:  r.sections,
+	})
+}
+
+// bar is a bar of a bar chart.
+type bar struct {
+	label string
+	value float64
+}
+
+func yearBars(years []activity.YearTotal) []bar {
+	bars := make([]bar, len(years))
+	for i, y := range years {
+		bars[i] = bar{label: strconv.Itoa(y.Year), value: float64(y.Total)}
+	}
+	return bars
+}
+
+// barChart draws bars as an SVG chart of horizontal bars, labeled with their
+// values with precision decimals.
+func barChart(title string, bars []bar, precision int) template.HTML {
+	const (
+		width      = 640
+		labelWidth = 140
+		valueWidth = 80
+		barHeight  = 20
+		gap        = 4
+		top        = 28
+	)
+	max := 0.0
+	for _, b := range bars {
+		max = math.Max(max, b.value)
+	}
+	height := top + len(bars)*(barHeight+gap) + gap
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height, width, height)
+	fmt.Fprintf(&svg, `<text x="0" y="18" class="title">%s</text>`, template.HTMLEscapeString(title))
+	for i, b := range bars {
+		y := top + i*(barHeight+gap)
+		length := 0.0
+		if max > 0 {
+			length = b.value / max * (width - labelWidth - valueWidth)
+		}
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="end">%s</text>`, labelWidth-6, y+barHeight-5, template.HTMLEscapeString(b.label))
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`, labelWidth, y, length, barHeight, colors[0])
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d">%s</text>`, labelWidth+length+6, y+barHeight-5, strconv.FormatFloat(b.value, 'f', precision, 64))
+	}
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+// trackpointMap draws the trackpoints of every user in their own color in the
+// box around the position at lat and lon, which is marked with a cross. East is
+// to the right and north up, with the longitudes shortened by the cosine of
+// lat.
+func trackpointMap(users []userRow, points [][]trackpoint.Trackpoint, lat, lon float64) template.HTML {
+	const (
+		size   = 480
+		legend = 160
+	)
+	scale := size / (2 * mapSpan)
+	cos := math.Cos(lat * math.Pi / 180)
+	project := func(pLat, pLon float64) (float64, float64) {
+		return size/2 + (pLon-lon)*cos*scale, size/2 - (pLat-lat)*scale
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, size+legend, size, size+legend, size)
+	fmt.Fprintf(&svg, `<rect x="0" y="0" width="%d" height="%d" class="map"/>`, size, size)
+	west, north := project(lat+mapBox, lon-mapBox)
+	east, south := project(lat-mapBox, lon+mapBox)
+	fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" class="box"/>`, west, north, east-west, south-north)
+	for i, userPoints := range points {
+		color := colors[i%len(colors)]
+		fmt.Fprintf(&svg, `<g fill="%s">`, color)
+		for _, p := range userPoints {
+			x, y := project(p.Lat, p.Lon)
+			fmt.Fprintf(&svg, `<circle cx="%.1f" cy="%.1f" r="2"/>`, x, y)
+		}
+		svg.WriteString(`</g>`)
+	}
+	fmt.Fprintf(&svg, `<path d="M%d %dh16M%d %dv16" stroke="#000" stroke-width="2"/>`, size/2-8, size/2, size/2, size/2-8)
+
+	// a scale bar of 100 meters
+	length := 100.0 / metersPerDegree * scale
+	fmt.Fprintf(&svg, `<path d="M10 %dh%.1f" stroke="#000" stroke-width="2"/>`, size-12, length)
+	fmt.Fprintf(&svg, `<text x="10" y="%d">100 m</text>`, size-18)
+
+	for i, usr := range users {
+		y := 20 + i*18
+		if y > size-10 {
+			break
+		}
+		fmt.Fprintf(&svg, `<circle cx="%d" cy="%d" r="5" fill="%s"/>`, size+16, y-4, colors[i%len(colors)])
+		fmt.Fprintf(&svg, `<text x="%d" y="%d">%s (%d)</text>`, size+28, y, template.HTMLEscapeString(usr.UserID), len(points[i]))
+	}
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+// sortedKeys returns the names of the arguments in order.
+func sortedKeys(args taskArgs) []string {
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Exercise report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f4f4f4; }
+.parameters { color: #666; font-family: monospace; }
+svg { display: block; margin: 1em 0; font-size: 12px; }
+svg .title { font-size: 14px; font-weight: bold; }
+svg .map { fill: #f8f8f8; stroke: #ccc; }
+svg .box { fill: none; stroke: #999; stroke-dasharray: 4 4; }
+</style>
+</head>
+<body>
+<h1>Exercise report</h1>
+<p>Generated {{.Generated}}</p>
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+{{if .Parameters}}<p class="parameters">{{.Parameters}}</p>{{end}}
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{range .Charts}}{{.}}
+{{end}}{{end}}
+</body>
+</html>
+`))
@@ -73,7 +73,7 @@ SELECT user_id, SUM(gained) FROM(
         IF(@prev_act = t.activity_id AND t.altitude > @prev_altitude, t.altitude - @prev_altitude, 0) as gained,
         IF(@prev_act<>t.activity_id, @prev_altitude:=9999, @prev_altitude:=t.altitude) as new_altitude,
         @prev_act := t.activity_id
-        FROM Trackpoint t INNER JOIN Activity a ON t.activity_id=a.id AND a.transportation_mode='walk' AND t.altitude<>-777,
+        FROM Trackpoint t INNER JOIN Activity a ON t.activity_id=a.id AND a.transportation_mode='walk' AND t.altitude IS NOT NULL,
         (SELECT @prev_altitude := 9999, @prev_act := null) var_init
         ORDER BY t.date_time, t.activity_id) sq
     WHERE activity_id IS NOT NULL
@@ -2,295 +2,679 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/geolife"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/progress"
+	"github.com/spacycoder/db_mysql/pkg/store"
 	"github.com/spacycoder/db_mysql/pkg/trackpoint"
 	"github.com/spacycoder/db_mysql/pkg/user"
 )
 
-func worker(tracker chan empty, users chan user.User, activityService *activity.Service, trackpointService *trackpoint.Service) {
-	trackpoints := make([]trackpoint.Trackpoint, 2500, 2500)
-	activities := make([]activity.Activity, 100, 100)
+// walkerCount is the number of goroutines comparing user folders with the ledger.
+const walkerCount = 2
+
+// loader loads the dataset in three stages connected by bounded channels:
+// walkers compare the files of each user with the ingestion ledger, parsers load
+// the labels of a user and parse its pending trajectories, and writers pack the
+// trajectories of many files into batches that are written in one transaction
+// together with their ledger entries. At most config.QueueSize users and files
+// wait between two stages, so memory use does not grow with the dataset.
+type loader struct {
+	ctx      context.Context
+	config   *Config
+	report   *LoadReport
+	progress *progress.Counter
+	failures chan<- FileFailure
+	store    store.Store
+}
 
-	for u := range users {
-		if u.HasLabels {
-			err := createActivities(u, activityService, activities)
-			if err != nil {
-				panic(err)
-			}
-		}
+// userJob is a user together with the files that still have to be loaded.
+type userJob struct {
+	user     user.User
+	files    []ledger.Entry
+	progress *userProgress
+}
 
-		path := fmt.Sprintf("./dataset/Data/%s/Trajectory", u.ID)
-		files, err := ioutil.ReadDir(path)
-		if err != nil {
-			panic(err)
-		}
+// userProgress counts the files of a user that are not finished yet.
+type userProgress struct {
+	remaining int64
+}
 
-		for _, file := range files {
-			err := createTrajectories(u, file.Name(), activityService, trackpointService, trackpoints)
-			if err != nil {
-				panic(err)
-			}
-		}
-	}
+// parsedFile is a trajectory that is ready to be written.
+type parsedFile struct {
+	entry       ledger.Entry
+	trackpoints []trackpoint.Trackpoint
+	match       FileMatch
+	labeled     bool
+	progress    *userProgress
+}
 
-	var e empty
-	tracker <- e
+// pendingBatch is the files a writer has parsed but not written yet.
+type pendingBatch struct {
+	files []parsedFile
+	rows  int
 }
 
-func loadDataset(config *Config, userService *user.Service, activityService *activity.Service, trackpointService *trackpoint.Service) error {
-	fmt.Println("Loading dataset")
+func loadDataset(config *Config, st store.Store) error {
+	fmt.Fprintln(config.log(), "Loading dataset")
+
+	report := &LoadReport{Started: time.Now(), MatchMode: config.MatchMode, Strategy: config.Strategy}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	insertUsers(userService)
-	usersChan := make(chan user.User, config.WorkerCount)
-	tracker := make(chan empty)
+	failures := make(chan FileFailure)
+	collected := make(chan empty)
+	go func() {
+		for f := range failures {
+			report.AddFailure(f)
+			if config.FailFast {
+				cancel()
+			}
+		}
+		var e empty
+		collected <- e
+	}()
 
-	users, err := userService.GetUsers()
+	if err := insertUsers(config, st.Users()); err != nil {
+		failures <- FileFailure{Error: err.Error()}
+	}
+
+	users, err := st.Users().GetUsers()
 	if err != nil {
+		close(failures)
+		<-collected
 		return err
 	}
+	report.Users = len(users)
+
+	counter := progress.NewCounter(len(users))
+	reporter := progress.NewReporter(counter, config.log())
+	reporter.Start()
+
+	l := &loader{
+		ctx:      ctx,
+		config:   config,
+		report:   report,
+		progress: counter,
+		failures: failures,
+		store:    st,
+	}
 
-	startTime := time.Now()
-	// start workers
+	usersChan := make(chan user.User, config.QueueSize)
+	jobs := make(chan userJob, config.QueueSize)
+	files := make(chan parsedFile, config.QueueSize)
+
+	var walkers, parsers, writers sync.WaitGroup
+	for i := 0; i < walkerCount; i++ {
+		walkers.Add(1)
+		go func() {
+			l.walk(usersChan, jobs)
+			walkers.Done()
+		}()
+	}
 	for i := 0; i < config.WorkerCount; i++ {
-		go worker(tracker, usersChan, activityService, trackpointService)
+		parsers.Add(1)
+		go func() {
+			l.parse(jobs, files)
+			parsers.Done()
+		}()
+	}
+	for i := 0; i < config.WriterCount; i++ {
+		writers.Add(1)
+		go func() {
+			l.write(files)
+			writers.Done()
+		}()
 	}
 
-	// push users to workers
+	// push users to walkers
+push:
 	for _, u := range users {
-		usersChan <- u
+		select {
+		case usersChan <- u:
+		case <-ctx.Done():
+			break push
+		}
 	}
-	close(usersChan)
 
-	// wait for workers to finish
-	for i := 0; i < config.WorkerCount; i++ {
-		<-tracker
+	// wait for each stage to finish before closing the input of the next one
+	close(usersChan)
+	walkers.Wait()
+	close(jobs)
+	parsers.Wait()
+	close(files)
+	writers.Wait()
+	close(failures)
+	<-collected
+	reporter.Stop()
+
+	report.Duration = time.Since(report.Started)
+	report.Print(config.log())
+	if config.ReportPath != "" {
+		if err := report.WriteJSON(config.ReportPath); err != nil {
+			return err
+		}
+		fmt.Fprintf(config.log(), "Wrote load report to %s\n", config.ReportPath)
 	}
 
-	fmt.Printf("Finished in %s\n", time.Since(startTime))
+	if len(report.Failures) > 0 {
+		return fmt.Errorf("%d users or files failed to load", len(report.Failures))
+	}
 	return nil
 }
 
-func insertUsers(userService *user.Service) error {
-	f, err := os.Open("./dataset/labeled_ids.txt")
-	if err != nil {
-		return err
-	}
-	scanner := bufio.NewScanner(f)
-	labeledUsers := make(map[string]struct{})
+// walk finds the pending files of each user.
+func (l *loader) walk(users <-chan user.User, jobs chan<- userJob) {
+	for u := range users {
+		if l.ctx.Err() != nil {
+			continue
+		}
 
-	for scanner.Scan() {
-		user := scanner.Text()
-		if strings.TrimSpace(user) == "" {
+		files, err := pendingFiles(l.config, u, l.store)
+		if err != nil {
+			l.failures <- FileFailure{User: u.ID, Error: err.Error()}
+		}
+		l.progress.UserWalked(len(files))
+		if len(files) == 0 {
+			l.progress.UserDone()
 			continue
 		}
 
-		labeledUsers[user] = struct{}{}
-	}
-	if err := scanner.Err(); err != nil {
-		return err
+		job := userJob{user: u, files: files, progress: &userProgress{remaining: int64(len(files))}}
+		select {
+		case jobs <- job:
+		case <-l.ctx.Done():
+		}
 	}
+}
 
-	files, err := ioutil.ReadDir("./dataset/Data/")
-	if err != nil {
-		return err
+// parse loads the labels of each user and parses its trajectories. When the
+// labels of a user fail, the user's trajectories are not loaded either, since
+// they could not be matched to activities.
+func (l *loader) parse(jobs <-chan userJob, out chan<- parsedFile) {
+	activities := make([]activity.Activity, 100, 100)
+
+	for job := range jobs {
+		if l.ctx.Err() != nil {
+			continue
+		}
+		l.parseUser(job, activities, out)
 	}
-	for _, file := range files {
-		_, exists := labeledUsers[file.Name()]
-		err := userService.CreateUser(file.Name(), exists)
+}
+
+func (l *loader) parseUser(job userJob, activities []activity.Activity, out chan<- parsedFile) {
+	u := job.user
+	var index *activity.Index
+	for i, file := range job.files {
+		if l.ctx.Err() != nil {
+			return
+		}
+
+		if file.Kind == ledger.LABELS {
+			if err := createActivities(l.config, file, l.store, activities); err != nil {
+				l.failures <- newFailure(file, err)
+				l.filesDone(job.progress, len(job.files)-i)
+				return
+			}
+			l.filesDone(job.progress, 1)
+			continue
+		}
+
+		// labels come first, so the index is built once they are loaded
+		if index == nil {
+			var err error
+			index, err = newUserIndex(u, l.store.Activities())
+			if err != nil {
+				l.failures <- FileFailure{User: u.ID, Error: err.Error()}
+				l.filesDone(job.progress, len(job.files)-i)
+				return
+			}
+		}
+
+		parsed, err := l.parseTrajectory(u, file, index)
 		if err != nil {
-			return err
+			l.failures <- newFailure(file, err)
+			l.filesDone(job.progress, 1)
+			continue
 		}
-	}
+		if parsed == nil {
+			l.filesDone(job.progress, 1)
+			continue
+		}
+		parsed.progress = job.progress
 
-	return nil
+		select {
+		case out <- *parsed:
+		case <-l.ctx.Done():
+			return
+		}
+	}
 }
 
-func createTrajectories(user user.User, fileName string, activityService *activity.Service, trackpointService *trackpoint.Service, trackpoints []trackpoint.Trackpoint) error {
-	filePath := fmt.Sprintf("./dataset/Data/%s/Trajectory/%s", user.ID, fileName)
-	f, err := os.Open(filePath)
+// parseTrajectory parses one .plt file. Files with more than config.MaxPoints
+// trackpoints are skipped or truncated depending on config.SizePolicy. It
+// returns nil for skipped files.
+func (l *loader) parseTrajectory(u user.User, file ledger.Entry, index *activity.Index) (*parsedFile, error) {
+	config := l.config
+	f, err := os.Open(config.datasetPath(file.Path))
 	if err != nil {
-		return err
+		return nil, l.store.Ledger().Fail(file, err)
 	}
 	defer f.Close()
 
-	valid, err := isValidLineCount(f)
-	if err != nil {
-		return err
-	}
-	if !valid {
-		return nil
-	}
-	f.Seek(0, io.SeekStart)
-	scanner := bufio.NewScanner(f)
-	// skip first 6 lines
-	scanner.Scan()
-	scanner.Scan()
-	scanner.Scan()
-	scanner.Scan()
-	scanner.Scan()
-	scanner.Scan()
-	var activities []activity.Activity
-	if user.HasLabels {
-		activities, err = activityService.GetActivitiesForUser(user.ID)
+	points := make([]trackpoint.Trackpoint, 0, config.MaxPoints)
+	count := 0
+	reader := geolife.NewTrajectoryReader(f)
+	for {
+		t, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return err
+			return nil, l.store.Ledger().Fail(file, err)
+		}
+
+		count++
+		if count > config.MaxPoints {
+			if config.SizePolicy == policySkip {
+				break
+			}
+			if config.SizePolicy == policyTruncate {
+				// keep reading to validate and count the rest of the file
+				continue
+			}
 		}
+		t.UserID = u.ID
+		points = append(points, t)
 	}
 
-	currActivityIndex := 0
-	trackpointIndex := 0
+	if count > config.MaxPoints {
+		file.SizePolicy = config.SizePolicy
+		file.MaxPoints = config.MaxPoints
+		limited := LimitedFile{User: u.ID, File: file.Path, Policy: config.SizePolicy, Points: count, Kept: len(points)}
+		if config.SizePolicy == policySkip {
+			limited.Points = -1
+			limited.Kept = 0
+			l.report.AddLimited(limited)
+			return nil, l.store.Ledger().Skip(file)
+		}
+		l.report.AddLimited(limited)
+	}
 
-	for scanner.Scan() {
-		row := scanner.Text()
-		row = strings.TrimSpace(row)
-		if row == "" {
+	parsed := &parsedFile{
+		entry:       file,
+		trackpoints: points,
+		match:       FileMatch{User: u.ID, File: file.Path},
+		labeled:     u.HasLabels,
+	}
+	parsed.entry.RowCount = len(points)
+	matchActivities(index, config.MatchMode, points, &parsed.match)
+	return parsed, nil
+}
+
+// write packs parsed files into batches of at most config.BatchRows trackpoints
+// and config.BatchBytes bytes. A file is never split across batches, so a file
+// larger than the budget is written in a batch of its own.
+func (l *loader) write(files <-chan parsedFile) {
+	// a sharded store gets a batch per shard, so that every transaction stays
+	// on one shard
+	batches := make(map[int]*pendingBatch)
+	buf := make([]trackpoint.Trackpoint, 0, l.config.BatchRows)
+
+	for f := range files {
+		if l.ctx.Err() != nil {
 			continue
 		}
 
-		cols := strings.Split(row, ",")
-		layout := "2006-01-02T15:04:05"
+		shard := l.shard(f.entry.UserID)
+		b := batches[shard]
+		if b == nil {
+			b = &pendingBatch{}
+			batches[shard] = b
+		}
 
-		date, err := time.Parse(layout, cols[5]+"T"+cols[6])
-		if err != nil {
-			return err
+		n := len(f.trackpoints)
+		if len(b.files) > 0 && (b.rows+n > l.config.BatchRows || (b.rows+n)*trackpoint.RowBytes > l.config.BatchBytes) {
+			buf = l.flush(b.files, buf)
+			b.files = b.files[:0]
+			b.rows = 0
 		}
-		var activityID *int
-		offset := 0
-		if len(activities) > 0 && currActivityIndex < len(activities) {
-			offset, activityID = getActivityIDForTrackpoint(date, activities[currActivityIndex:])
-			currActivityIndex += offset
+		b.files = append(b.files, f)
+		b.rows += n
+	}
+
+	for _, b := range batches {
+		if len(b.files) > 0 && l.ctx.Err() == nil {
+			buf = l.flush(b.files, buf)
 		}
-		lat, err := strconv.ParseFloat(cols[0], 64)
-		if err != nil {
+	}
+}
+
+// shard returns the shard of the store userID is written to, 0 if the store is
+// not sharded.
+func (l *loader) shard(userID string) int {
+	if router, ok := l.store.(store.Router); ok {
+		return router.Shard(userID)
+	}
+	return 0
+}
+
+// flush writes a batch. If the batch fails, its files are retried one by one so
+// that only the files that cause the error are reported. buf is reused for the
+// trackpoints of the batch and returned for the next call.
+func (l *loader) flush(batch []parsedFile, buf []trackpoint.Trackpoint) []trackpoint.Trackpoint {
+	entries := make([]ledger.Entry, len(batch))
+	buf = buf[:0]
+	for i, f := range batch {
+		entries[i] = f.entry
+		buf = append(buf, f.trackpoints...)
+	}
+
+	err := store.InTx(l.store, func(tx store.Tx) error {
+		if err := tx.InsertTrackpoints(buf); err != nil {
 			return err
 		}
-		lon, err := strconv.ParseFloat(cols[1], 64)
-		if err != nil {
-			return err
+		return tx.Record(entries...)
+	})
+	if err == nil {
+		l.report.AddTrackpoints(len(buf))
+		l.progress.AddTrackpoints(len(buf))
+		for _, f := range batch {
+			if f.labeled {
+				l.report.AddMatch(f.match)
+			}
+			l.filesDone(f.progress, 1)
 		}
-		alt, err := strconv.ParseFloat(cols[3], 64) // alt is in some cases float
+		return buf
+	}
+
+	if len(batch) == 1 {
+		l.failures <- newFailure(batch[0].entry, l.store.Ledger().Fail(batch[0].entry, err))
+		l.filesDone(batch[0].progress, 1)
+		return buf
+	}
+	for i := range batch {
+		buf = l.flush(batch[i:i+1], buf)
+	}
+	return buf
+}
+
+// filesDone counts n finished files of a user and the user once all of its
+// files are finished.
+func (l *loader) filesDone(p *userProgress, n int) {
+	l.progress.FilesDone(n)
+	if atomic.AddInt64(&p.remaining, -int64(n)) == 0 {
+		l.progress.UserDone()
+	}
+}
+
+// pendingFiles compares the files of a user with the ingestion ledger and returns the
+// ones that still have to be loaded, labels first. If a file that was already loaded
+// has changed, disappeared or was skipped or cut off under other size limits than
+// config's, everything loaded for the user is removed and all of the user's files are
+// returned, since activity ids and trackpoints depend on each other.
+func pendingFiles(config *Config, u user.User, st store.Store) ([]ledger.Entry, error) {
+	var files []ledger.Entry
+	if u.HasLabels {
+		path := fmt.Sprintf("Data/%s/labels.txt", u.ID)
+		info, err := os.Stat(config.datasetPath(path))
 		if err != nil {
-			return err
+			return nil, err
 		}
-		days, err := strconv.ParseFloat(cols[4], 64)
-		if err != nil {
-			return err
+		files = append(files, newEntry(u, ledger.LABELS, path, info))
+	}
+
+	infos, err := ioutil.ReadDir(config.datasetPath(fmt.Sprintf("Data/%s/Trajectory", u.ID)))
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		path := fmt.Sprintf("Data/%s/Trajectory/%s", u.ID, info.Name())
+		files = append(files, newEntry(u, ledger.TRAJECTORY, path, info))
+	}
+
+	recorded, err := st.Ledger().GetEntriesForUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	finished := make(map[string]bool)
+	for _, file := range files {
+		rec, ok := recorded[file.Path]
+		if !ok {
+			continue
+		}
+		delete(recorded, file.Path)
+		if !rec.Finished() {
+			continue
+		}
+		// a file skipped or cut off under other limits has other rows now
+		if !rec.SameLimits(config.SizePolicy, config.MaxPoints) {
+			changed = true
+			break
+		}
+		if !rec.SameStat(file) {
+			sum, err := checksum(config.datasetPath(file.Path))
+			if err != nil {
+				return nil, err
+			}
+			if sum != rec.Checksum {
+				changed = true
+				break
+			}
+		}
+		finished[file.Path] = true
+	}
+	for _, rec := range recorded {
+		if rec.Finished() {
+			changed = true
 		}
+	}
+	// trajectories loaded before the labels would be missing their activity ids
+	if u.HasLabels && !finished[files[0].Path] && len(finished) > 0 {
+		changed = true
+	}
 
-		trackpoints[trackpointIndex].Altitude = int(alt)
-		trackpoints[trackpointIndex].UserID = user.ID
-		trackpoints[trackpointIndex].Lat = lat
-		trackpoints[trackpointIndex].Lon = lon
-		trackpoints[trackpointIndex].DateTime = date
-		trackpoints[trackpointIndex].DateDays = days
-		trackpoints[trackpointIndex].ActivityID = activityID
-		trackpointIndex++
+	if changed {
+		fmt.Fprintf(config.log(), "Files of user %s changed or were loaded with other size limits since the last load, reloading user\n", u.ID)
+		if err := st.Trackpoints().DeleteForUser(u.ID); err != nil {
+			return nil, err
+		}
+		if err := st.Activities().DeleteForUser(u.ID); err != nil {
+			return nil, err
+		}
+		if err := st.Ledger().DeleteForUser(u.ID); err != nil {
+			return nil, err
+		}
+		finished = nil
 	}
 
-	if trackpointIndex > 0 {
-		return trackpointService.BulkInsertTrackpoint(trackpoints, trackpointIndex)
+	pending := files[:0]
+	for _, file := range files {
+		if finished[file.Path] {
+			continue
+		}
+		sum, err := checksum(config.datasetPath(file.Path))
+		if err != nil {
+			return nil, err
+		}
+		file.Checksum = sum
+		pending = append(pending, file)
 	}
+	return pending, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return err
+func newEntry(u user.User, kind ledger.Kind, path string, info os.FileInfo) ledger.Entry {
+	return ledger.Entry{
+		Path:    path,
+		UserID:  u.ID,
+		Kind:    kind,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
 	}
-	return nil
 }
 
-func createActivities(user user.User, activityService *activity.Service, activities []activity.Activity) error {
-	activityPath := fmt.Sprintf("./dataset/Data/%s/labels.txt", user.ID)
-	f, err := os.Open(activityPath)
+func checksum(path string) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
-	activityIndex := 0
-	// skip first line
-	scanner.Scan()
-	for scanner.Scan() {
-		row := scanner.Text()
-		row = strings.TrimSpace(row)
-		if row == "" {
-			continue
-		}
-		cols := strings.Split(row, "\t")
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-		startDate, err := time.Parse(dateLayout, cols[0])
-		if err != nil {
-			return err
-		}
-		endDate, err := time.Parse(dateLayout, cols[1])
+// datasetPath returns the path of a file given relative to the dataset root.
+func (c *Config) datasetPath(path string) string {
+	return filepath.Join(c.DatasetRoot, path)
+}
+
+func insertUsers(config *Config, users store.UserStore) error {
+	labeledUsers, err := readLabeledUsers(config)
+	if err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(config.datasetPath("Data"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		_, exists := labeledUsers[file.Name()]
+		err := users.CreateUser(file.Name(), exists)
 		if err != nil {
 			return err
 		}
+	}
 
-		activities[activityIndex].UserID = user.ID
-		activities[activityIndex].TransportationMode = cols[2]
-		activities[activityIndex].StartDateTime = startDate
-		activities[activityIndex].EndDateTime = endDate
-		activityIndex++
+	return nil
+}
 
-		if activityIndex >= len(activities) {
-			activityService.BulkCreateActivity(activities, activityIndex)
-			activityIndex = 0
-		}
+func readLabeledUsers(config *Config) (map[string]struct{}, error) {
+	f, err := os.Open(config.datasetPath("labeled_ids.txt"))
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
 
-	if activityIndex > 0 {
-		activityService.BulkCreateActivity(activities, activityIndex)
-		activityIndex = 0
+	labeledUsers := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		user := strings.TrimSpace(scanner.Text())
+		if user == "" {
+			continue
+		}
+		labeledUsers[user] = struct{}{}
 	}
-	return nil
+	return labeledUsers, scanner.Err()
 }
 
-func isValidLineCount(r io.Reader) (bool, error) {
-	buf := make([]byte, 1024*1024)
-	count := 0
-	lineSep := []byte{'\n'}
+func createActivities(config *Config, file ledger.Entry, st store.Store, activities []activity.Activity) error {
+	f, err := os.Open(config.datasetPath(file.Path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	for {
-		c, err := r.Read(buf)
-		count += bytes.Count(buf[:c], lineSep)
-		if count > validLineCount {
-			return false, nil
+	// all activities of a user are inserted in one transaction so that a failed
+	// labels.txt never leaves half of its activities behind
+	err = store.InTx(st, func(tx store.Tx) error {
+		reader := geolife.NewLabelReader(f)
+		activityIndex := 0
+		activityCount := 0
+		for {
+			a, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			a.UserID = file.UserID
+			activities[activityIndex] = a
+			activityIndex++
+
+			if activityIndex >= len(activities) {
+				if err := tx.CreateActivities(activities[:activityIndex]); err != nil {
+					return err
+				}
+				activityCount += activityIndex
+				activityIndex = 0
+			}
 		}
-		switch {
-		case err == io.EOF:
-			return true, nil
 
-		case err != nil:
-			return false, err
+		if err := tx.CreateActivities(activities[:activityIndex]); err != nil {
+			return err
 		}
+		file.RowCount = activityCount + activityIndex
+		return tx.Record(file)
+	})
+	if err != nil {
+		return st.Ledger().Fail(file, err)
 	}
+	return nil
 }
 
-func getActivityIDForTrackpoint(timestamp time.Time, activities []activity.Activity) (int, *int) {
-	indexOffset := 0
-	if activities[indexOffset].StartDateTime.After(timestamp) {
-		return 0, nil
+func newUserIndex(u user.User, activities store.ActivityStore) (*activity.Index, error) {
+	if !u.HasLabels {
+		return activity.NewIndex(nil), nil
 	}
-	if activities[indexOffset].EndDateTime.After(timestamp) {
-		return 0, &activities[indexOffset].ID
+	labels, err := activities.GetActivitiesForUser(u.ID)
+	if err != nil {
+		return nil, err
 	}
+	return activity.NewIndex(labels), nil
+}
 
-	indexOffset++
-	if indexOffset >= len(activities) {
-		return indexOffset, nil
+// matchActivities sets the activity id of the trackpoints of one trajectory and
+// counts the outcome in match. In contained mode a trackpoint belongs to the
+// label whose [start, end] interval contains it, and to the one that started last
+// if several do. In strict mode the whole trajectory belongs to a label only if
+// the label starts at its first and ends at its last trackpoint.
+func matchActivities(index *activity.Index, matchMode string, trackpoints []trackpoint.Trackpoint, match *FileMatch) {
+	if len(trackpoints) == 0 {
+		return
 	}
-	if activities[indexOffset].StartDateTime.After(timestamp) {
-		return indexOffset, nil
+
+	if matchMode == matchStrict {
+		a, count := index.Exact(trackpoints[0].DateTime, trackpoints[len(trackpoints)-1].DateTime)
+		var activityID *int
+		if a != nil {
+			activityID = &a.ID
+		}
+		for i := range trackpoints {
+			trackpoints[i].ActivityID = activityID
+		}
+		match.count(count, len(trackpoints))
+		return
 	}
 
-	return indexOffset, &activities[indexOffset].ID
+	for i := range trackpoints {
+		a, count := index.Covering(trackpoints[i].DateTime)
+		trackpoints[i].ActivityID = nil
+		if a != nil {
+			trackpoints[i].ActivityID = &a.ID
+		}
+		match.count(count, 1)
+	}
 }
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/store/memory"
+)
+
+// testDataset is a small dataset of two labeled users, 010 and 011, and an
+// unlabeled user, 012.
+const testDataset = "testdata/dataset"
+
+// testConfig returns the settings of load for the dataset in root, with the
+// output of the loader discarded.
+func testConfig(t *testing.T, root string) *Config {
+	log, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { log.Close() })
+
+	config := defaultConfig()
+	config.DatasetRoot = root
+	config.WorkerCount = 2
+	config.WriterCount = 2
+	config.FailFast = true
+	config.Log = log
+	return &config
+}
+
+// loadTestDataset loads the dataset of config into a new memory store.
+func loadTestDataset(t *testing.T, config *Config) store.Store {
+	st := memory.New()
+	if err := st.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	load(t, config, st)
+	return st
+}
+
+func load(t *testing.T, config *Config, st store.Store) {
+	if err := loadDataset(config, st); err != nil {
+		t.Fatalf("loadDataset: %v", err)
+	}
+}
+
+// copyDataset copies the test dataset to a temporary folder, where the files
+// can be changed.
+func copyDataset(t *testing.T) string {
+	root := t.TempDir()
+	err := filepath.Walk(testDataset, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(testDataset, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(root, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func copyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func checkCount(t *testing.T, name string, count func() (int, error), want int) {
+	t.Helper()
+	got, err := count()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if got != want {
+		t.Errorf("%s = %d, want %d", name, got, want)
+	}
+}
+
+// checkTrackpoints checks the number of trackpoints of every user.
+func checkTrackpoints(t *testing.T, st store.Store, want map[string]int) {
+	t.Helper()
+	for userID, count := range want {
+		checkCount(t, "trackpoints of "+userID, func() (int, error) {
+			return st.Trackpoints().GetCountForUser(userID)
+		}, count)
+	}
+}
+
+func TestLoadDataset(t *testing.T) {
+	st := loadTestDataset(t, testConfig(t, testDataset))
+
+	checkCount(t, "users", st.Users().GetCount, 3)
+	checkCount(t, "activities", st.Activities().GetCount, 6)
+	checkCount(t, "trackpoints", st.Trackpoints().GetCount, 31)
+	checkTrackpoints(t, st, map[string]int{"010": 16, "011": 12, "012": 3})
+
+	users, err := st.Users().GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	labeled := map[string]bool{"010": true, "011": true, "012": false}
+	for _, usr := range users {
+		if usr.HasLabels != labeled[usr.ID] {
+			t.Errorf("user %s has labels %v, want %v", usr.ID, usr.HasLabels, labeled[usr.ID])
+		}
+	}
+
+	// the trackpoints of the walk of 010 on 2008-10-28 are matched to its
+	// label, the ones of 2008-10-30 to none
+	points, err := st.Trackpoints().GetForUser("010", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activities, err := st.Activities().GetActivitiesForUser("010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched := make(map[int]int)
+	unmatched := 0
+	for _, p := range points {
+		if p.ActivityID == nil {
+			unmatched++
+		} else {
+			matched[*p.ActivityID]++
+		}
+	}
+	if unmatched != 2 {
+		t.Errorf("%d trackpoints of 010 without an activity, want 2", unmatched)
+	}
+	for _, act := range activities {
+		want := map[string]int{"walk": 6, "bus": 4, "Walk": 4}[act.TransportationMode]
+		if matched[act.ID] != want {
+			t.Errorf("%d trackpoints in the %s activity of 010, want %d", matched[act.ID], act.TransportationMode, want)
+		}
+	}
+
+	entries, err := st.Ledger().GetEntriesForUser("010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("%d ledger entries of 010, want the labels and 4 trajectories", len(entries))
+	}
+	for path, entry := range entries {
+		if entry.Status != ledger.DONE {
+			t.Errorf("%s is %s, want %s", path, entry.Status, ledger.DONE)
+		}
+	}
+}
+
+func TestLoadResume(t *testing.T) {
+	root := copyDataset(t)
+	config := testConfig(t, root)
+	st := loadTestDataset(t, config)
+	before, err := st.Ledger().GetEntriesForUser("011")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing changed, so nothing is loaded twice
+	load(t, config, st)
+	checkCount(t, "trackpoints", st.Trackpoints().GetCount, 31)
+	checkCount(t, "activities", st.Activities().GetCount, 6)
+	after, err := st.Ledger().GetEntriesForUser("011")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("%d ledger entries of 011 after loading again, want %d", len(after), len(before))
+	}
+
+	// a trackpoint more in a trajectory of 011 reloads 011
+	path := filepath.Join(root, "Data", "011", "Trajectory", "20081101100000.plt")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString("40.01,116.506,0,50,39753.4173611111,2008-11-01,10:03:00\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	load(t, config, st)
+	checkCount(t, "trackpoints", st.Trackpoints().GetCount, 32)
+	checkCount(t, "activities", st.Activities().GetCount, 6)
+	checkTrackpoints(t, st, map[string]int{"010": 16, "011": 13, "012": 3})
+}
+
+func TestSizePolicies(t *testing.T) {
+	// with at most 5 trackpoints per trajectory, the walk of 010 on 2008-10-28
+	// with 6 trackpoints and the ride of 011 on 2008-10-28 with 7 are too long
+	tests := []struct {
+		policy string
+		want   map[string]int
+	}{
+		{policySkip, map[string]int{"010": 10, "011": 5, "012": 3}},
+		{policyTruncate, map[string]int{"010": 15, "011": 10, "012": 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			config := testConfig(t, testDataset)
+			config.MaxPoints = 5
+			config.SizePolicy = test.policy
+			st := loadTestDataset(t, config)
+			checkTrackpoints(t, st, test.want)
+
+			entries, err := st.Ledger().GetEntriesForUser("010")
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry := entries[filepath.Join("Data", "010", "Trajectory", "20081028120000.plt")]
+			if entry.SizePolicy != test.policy || entry.MaxPoints != 5 {
+				t.Errorf("ledger recorded %q with %d trackpoints, want %q with 5", entry.SizePolicy, entry.MaxPoints, test.policy)
+			}
+		})
+	}
+}
+
+func TestSizePolicyChanged(t *testing.T) {
+	config := testConfig(t, testDataset)
+	config.MaxPoints = 5
+	st := loadTestDataset(t, config)
+	checkTrackpoints(t, st, map[string]int{"010": 10, "011": 5, "012": 3})
+
+	// the skipped files are loaded once they fit
+	config.MaxPoints = defaultConfig().MaxPoints
+	load(t, config, st)
+	checkTrackpoints(t, st, map[string]int{"010": 16, "011": 12, "012": 3})
+	checkCount(t, "activities", st.Activities().GetCount, 6)
+
+	// and truncated with another policy
+	config.MaxPoints = 5
+	config.SizePolicy = policyTruncate
+	load(t, config, st)
+	checkTrackpoints(t, st, map[string]int{"010": 15, "011": 10, "012": 3})
+	checkCount(t, "activities", st.Activities().GetCount, 6)
+}
@@ -4,251 +4,303 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"flag"
 	"fmt"
 	"log"
 	"os"
-	"runtime"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/spacycoder/db_mysql/pkg/activity"
-	"github.com/spacycoder/db_mysql/pkg/trackpoint"
-	"github.com/spacycoder/db_mysql/pkg/user"
+	_ "github.com/lib/pq"
+	"github.com/spacycoder/db_mysql/pkg/replica"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/store/memory"
+	"github.com/spacycoder/db_mysql/pkg/store/mongo"
+	"github.com/spacycoder/db_mysql/pkg/store/mysql"
+	"github.com/spacycoder/db_mysql/pkg/store/postgres"
+	"github.com/spacycoder/db_mysql/pkg/store/sqlite"
 )
 
-const dateLayout string = "2006/01/02 15:04:05"
-const validLineCount int = 2506
+const (
+	driverMySQL    = "mysql"
+	driverSQLite   = "sqlite"
+	driverPostgres = "postgres"
+	driverMongo    = "mongo"
+	driverMemory   = "memory"
+)
+
+const (
+	policySkip     = "skip"
+	policyTruncate = "truncate"
+)
+
+const (
+	matchContained = "contained"
+	matchStrict    = "strict"
+)
 
 type empty struct{}
 
 type Config struct {
 	WorkerCount int
-	User        string
-	Password    string
-	DbURL       string
-	Operation   string
+	WriterCount int
+	BatchRows   int
+	BatchBytes  int
+	QueueSize   int
+	Driver      string
+	// DSN overrides the data source built from Host, Port, User, Password and
+	// Database
+	DSN string
+	// Shards are the MySQL servers the users are spread over, if set, as
+	// go-sql-driver DSNs optionally prefixed with a name and =
+	Shards []string
+	// OldShards are the shards the users were spread over before a rebalance
+	OldShards []string
+	// Routes is the name of the shard whose ShardRoute table keeps the routes
+	// of the users that move between shards, shard.Name(0) if empty
+	Routes string
+	// Replicas are the go-sql-driver DSNs of the MySQL read replicas the task
+	// queries are sent to
+	Replicas []string
+	// MaxReplicaLag is how far a replica may be behind before the primary is
+	// read instead, not checked if 0
+	MaxReplicaLag time.Duration
+	Host          string
+	Port          int
+	User          string
+	Password      string
+	Database      string
+	// DatasetRoot is the folder with labeled_ids.txt and the Data folder of the
+	// Geolife dataset
+	DatasetRoot string
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime size the connection pool of
+	// every database server
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	FailFast        bool
+	ReportPath      string
+	MatchMode       string
+	SizePolicy      string
+	MaxPoints       int
+	Strategy        string
+	// Log is where the loader writes its progress and report, standard output
+	// if nil
+	Log *os.File
 }
 
-func main() {
-	cpus := runtime.NumCPU()
-	fmt.Printf("Number of CPUs: %d\n", cpus)
-
-	operation := flag.String("op", "exercises", "load,exercises,drop")
-	flag.Parse()
-
-	fmt.Println(*operation)
-	cfg := Config{
-		WorkerCount: cpus * 2,
-		User:        "lars",
-		Password:    "lars",
-		//DbURL:       "127.0.0.1",
-		DbURL:     "tdt4225-29.idi.ntnu.no",
-		Operation: *operation,
-	}
-	if err := run(&cfg); err != nil {
-		log.Fatalf("Exited with error: %v\n", err)
+// defaultConfig returns the settings of the loader at the defaults of their
+// flags.
+func defaultConfig() Config {
+	return Config{
+		WriterCount: 4,
+		BatchBytes:  8 << 20,
+		QueueSize:   16,
+		BatchRows:   20000,
+		MatchMode:   matchContained,
+		SizePolicy:  policySkip,
+		MaxPoints:   2500,
+		Strategy:    mysql.StrategyInsert,
 	}
-
-	fmt.Println("Exited without error")
 }
 
-func run(config *Config) error {
-	db, err := newDB(config.DbURL, config.User, config.Password, "strava")
-	if err != nil {
-		return err
+// log returns the file the loader writes its progress and report to.
+func (c *Config) log() *os.File {
+	if c.Log == nil {
+		return os.Stdout
 	}
-	defer db.Close()
+	return c.Log
+}
 
-	context, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-	err = db.PingContext(context)
-	if err != nil {
-		return err
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		log.Fatalf("Exited with error: %v\n", err)
 	}
+}
 
-	fmt.Println("Successfully connected to database")
-
-	_, err = os.Stat("./dataset")
-	if os.IsNotExist(err) {
-		return errors.New("./dataset folder not found")
-	}
+// withStore opens the store of config, runs fn with it and closes it.
+func withStore(config *Config, fn func(st store.Store) error) error {
+	st, err := openStore(config)
 	if err != nil {
 		return err
 	}
+	defer st.Close()
 
-	userService, err := user.New(db)
-	if err != nil {
-		return err
-	}
+	// the results of the tasks may be written to standard output as JSON or CSV
+	fmt.Fprintln(os.Stderr, "Successfully connected to database")
+	return fn(st)
+}
 
-	activityService, err := activity.New(db)
-	if err != nil {
-		return err
+// openStore connects to the database of config.Driver and returns the store on top of it.
+func openStore(config *Config) (store.Store, error) {
+	if config.Driver == driverSQLite {
+		return sqlite.Open(config.DSN)
 	}
 
-	trackpointService, err := trackpoint.New(db)
-	if err != nil {
-		return err
+	if config.Driver == driverMongo {
+		return mongo.Open(config.DSN)
 	}
 
-	switch config.Operation {
-	case "load":
-
-		if err := userService.CreateTable(); err != nil {
-			return err
-		}
-
-		if err := activityService.CreateTable(); err != nil {
-			return err
-		}
-
-		if err := trackpointService.CreateTable(); err != nil {
-			return err
-		}
+	if config.Driver == driverMemory {
+		return openMemory(config)
+	}
 
-		if err = activityService.LoadStatements(); err != nil {
-			return err
+	if len(config.Shards) > 0 {
+		st, err := openShards(config)
+		if err != nil {
+			return nil, err
 		}
+		return st, nil
+	}
 
-		if err = trackpointService.LoadStatements(); err != nil {
-			return err
+	driverName := "mysql"
+	dataSource := config.DSN
+	if config.Driver == driverPostgres {
+		driverName = "postgres"
+	} else if dataSource == "" {
+		if config.User == "" {
+			return nil, errors.New("no MySQL user, set --db-user or use --dsn")
 		}
-
-		if err = userService.LoadStatements(); err != nil {
-			return err
+		if config.Password == "" {
+			return nil, errors.New("no MySQL password, set STRAVA_PASSWORD or the password key of the config file")
 		}
+		dataSource = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", config.User, config.Password, config.Host, config.Port, config.Database)
+	}
+	db, err := connect(config, driverName, dataSource)
+	if err != nil {
+		return nil, err
+	}
 
-		err := loadDataset(config, userService, activityService, trackpointService)
+	if config.Driver == driverPostgres {
+		st, err := postgres.New(db)
 		if err != nil {
-			return err
-		}
-	case "exercises":
-		if err = activityService.LoadStatements(); err != nil {
-			return err
-		}
-
-		if err = trackpointService.LoadStatements(); err != nil {
-			return err
+			db.Close()
+			return nil, err
 		}
+		return st, nil
+	}
 
-		if err = userService.LoadStatements(); err != nil {
-			return err
-		}
-		err := runExercises(activityService, trackpointService, userService)
+	opts := mysql.Options{Strategy: config.Strategy}
+	if len(config.Replicas) > 0 {
+		set, err := openReplicas(db, config)
 		if err != nil {
-			return err
+			db.Close()
+			return nil, err
 		}
-	case "drop":
-		_, err = db.Exec("DROP TABLE Trackpoint")
-		if err != nil {
-			return err
-		}
-		_, err = db.Exec("DROP TABLE Activity")
-		if err != nil {
-			return err
-		}
-		_, err = db.Exec("DROP TABLE User")
-		if err != nil {
-			return err
-		}
-		return nil
-	default:
-		return errors.New("Invalid operation: " + config.Operation)
+		opts.Replicas = set
 	}
 
-	return nil
-}
-
-func newDB(host, user, password, dbname string) (*sql.DB, error) {
-	dataSource := fmt.Sprintf("%s:%s@tcp(%s:3306)/%s?parseTime=true", user, password, host, dbname)
-	db, err := sql.Open("mysql", dataSource)
+	st, err := mysql.New(db, opts)
 	if err != nil {
+		if opts.Replicas != nil {
+			opts.Replicas.Close()
+		}
+		db.Close()
 		return nil, err
 	}
-
-	db.SetConnMaxLifetime(time.Minute * 3)
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(10)
-	return db, nil
+	return st, nil
 }
 
-func runExercises(activityService *activity.Service, trackpointService *trackpoint.Service, userService *user.Service) error {
-	fmt.Println("------------------")
-	fmt.Println("      Task 1      ")
-	fmt.Println("------------------")
-	if err := task1(activityService, userService, trackpointService); err != nil {
-		return err
-	}
-
-	fmt.Println("------------------")
-	fmt.Println("      Task 2      ")
-	fmt.Println("------------------")
-	if err := task2(activityService); err != nil {
-		return err
+// openMemory loads the dataset of config into a store in memory, with the
+// settings of load that config has no flags for at their defaults of
+// defaultConfig. The loader
+// writes to standard error, as the results of the tasks may be written to
+// standard output, and no load report is written.
+func openMemory(config *Config) (store.Store, error) {
+	defaults := defaultConfig()
+	loadConfig := *config
+	loadConfig.ReportPath = ""
+	loadConfig.Log = os.Stderr
+	for _, setting := range []struct {
+		value    *int
+		fallback int
+	}{
+		{&loadConfig.WriterCount, defaults.WriterCount},
+		{&loadConfig.BatchBytes, defaults.BatchBytes},
+		{&loadConfig.QueueSize, defaults.QueueSize},
+		{&loadConfig.MaxPoints, defaults.MaxPoints},
+		{&loadConfig.BatchRows, defaults.BatchRows},
+	} {
+		if *setting.value == 0 {
+			*setting.value = setting.fallback
+		}
 	}
-
-	fmt.Println("------------------")
-	fmt.Println("      Task 3      ")
-	fmt.Println("------------------")
-	if err := task3(activityService); err != nil {
-		return err
+	for _, setting := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&loadConfig.MatchMode, defaults.MatchMode},
+		{&loadConfig.SizePolicy, defaults.SizePolicy},
+		{&loadConfig.Strategy, defaults.Strategy},
+	} {
+		if *setting.value == "" {
+			*setting.value = setting.fallback
+		}
 	}
 
-	fmt.Println("------------------")
-	fmt.Println("      Task 4      ")
-	fmt.Println("------------------")
-	if err := task4(userService); err != nil {
-		return err
+	st := memory.New()
+	if err := st.Prepare(); err != nil {
+		return nil, err
 	}
-
-	fmt.Println("------------------")
-	fmt.Println("      Task 5      ")
-	fmt.Println("------------------")
-	if err := task5(activityService); err != nil {
-		return err
+	if err := loadDataset(&loadConfig, st); err != nil {
+		return nil, err
 	}
+	return st, nil
+}
 
-	fmt.Println("------------------")
-	fmt.Println("      Task 6      ")
-	fmt.Println("------------------")
-	if err := task6(activityService); err != nil {
-		return err
+// openReplicas connects to the read replicas of config.Replicas, which replicate
+// the MySQL server primary.
+func openReplicas(primary *sql.DB, config *Config) (*replica.Set, error) {
+	var replicas []*sql.DB
+	for i, dsn := range config.Replicas {
+		db, err := connect(config, "mysql", dsn)
+		if err != nil {
+			for _, opened := range replicas {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+		replicas = append(replicas, db)
 	}
+	return replica.New(primary, replicas, replica.Options{MaxLag: config.MaxReplicaLag}), nil
+}
 
-	fmt.Println("------------------")
-	fmt.Println("      Task 7      ")
-	fmt.Println("------------------")
-	if err := task7(activityService); err != nil {
-		return err
+// connect opens a database with the connection pool of config and checks that
+// it can be reached.
+func connect(config *Config, driverName, dataSource string) (*sql.DB, error) {
+	db, err := newDB(config, driverName, dataSource)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Println("------------------")
-	fmt.Println("      Task 8      ")
-	fmt.Println("------------------")
-	if err := task8(userService); err != nil {
-		return err
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
 	}
+	return db, nil
+}
 
-	fmt.Println("------------------")
-	fmt.Println("      Task 9      ")
-	fmt.Println("------------------")
-	if err := task9(userService); err != nil {
-		return err
+// splitList splits a comma separated list and drops empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	fmt.Println("------------------")
-	fmt.Println("      Task 10      ")
-	fmt.Println("------------------")
-	if err := task10(userService); err != nil {
-		return err
+func newDB(config *Config, driverName, dataSource string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSource)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Println("------------------")
-	fmt.Println("      Task 11      ")
-	fmt.Println("------------------")
-	if err := task11(activityService); err != nil {
-		return err
-	}
-	return nil
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	return db, nil
 }
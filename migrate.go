@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spacycoder/db_mysql/pkg/store"
+)
+
+// runMigrate runs "up", "down" or "status" for the migrate command.
+func runMigrate(migrator store.Migrator, command string) error {
+	switch command {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Printf("Applied %d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		return nil
+	case "down":
+		m, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			fmt.Println("No migration to roll back")
+			return nil
+		}
+		fmt.Printf("Rolled back %d %s\n", m.Version, m.Name)
+		return nil
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Version", "Name", "Applied at"})
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			table.Append([]string{strconv.Itoa(s.Version), s.Name, appliedAt})
+		}
+		table.Render()
+		return nil
+	default:
+		return errors.New("Invalid migrate command, expected up, down or status: " + command)
+	}
+}
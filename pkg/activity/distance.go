@@ -0,0 +1,21 @@
+package activity
+
+import "math"
+
+// Distance returns the great-circle distance in kilometers between two coordinates.
+func Distance(fromLat float64, fromLon float64, toLat float64, toLon float64) float64 {
+	lat1 := fromLat * math.Pi / 180.0
+	lon1 := fromLon * math.Pi / 180.0
+	lat2 := toLat * math.Pi / 180.0
+	lon2 := toLon * math.Pi / 180.0
+
+	diffLat := lat2 - lat1
+	diffLon := lon2 - lon1
+
+	ans := math.Pow(math.Sin(diffLat/2.0), 2) + (math.Cos(lat1) * math.Cos(lat2) * math.Pow(math.Sin(diffLon/2.0), 2))
+	ans = 2.0 * math.Asin(math.Sqrt(ans))
+
+	earthRadius := 6371.0
+
+	return ans * earthRadius
+}
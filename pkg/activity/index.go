@@ -0,0 +1,86 @@
+package activity
+
+import (
+	"sort"
+	"time"
+)
+
+// Index is a sorted interval index over the activities of one user. Activities
+// are ordered by start time and maxEnd[i] holds the latest end time of
+// activities[0..i], so a lookup can stop as soon as no earlier activity can
+// reach the timestamp anymore.
+type Index struct {
+	activities []Activity
+	maxEnd     []time.Time
+}
+
+func NewIndex(activities []Activity) *Index {
+	sorted := make([]Activity, len(activities))
+	copy(sorted, activities)
+	sort.Stable(SortByDate(sorted))
+
+	maxEnd := make([]time.Time, len(sorted))
+	for i, a := range sorted {
+		maxEnd[i] = a.EndDateTime
+		if i > 0 && maxEnd[i-1].After(a.EndDateTime) {
+			maxEnd[i] = maxEnd[i-1]
+		}
+	}
+	return &Index{activities: sorted, maxEnd: maxEnd}
+}
+
+func (x *Index) Len() int {
+	return len(x.activities)
+}
+
+// Covering returns the activity whose [start, end] interval contains t and
+// started last, together with the number of activities that contain t. A count
+// above one means the match is ambiguous.
+func (x *Index) Covering(t time.Time) (*Activity, int) {
+	var match *Activity
+	count := 0
+	x.EachCovering(t, func(a *Activity) {
+		if match == nil {
+			match = a
+		}
+		count++
+	})
+	return match, count
+}
+
+// EachCovering calls fn for every activity whose [start, end] interval contains
+// t, latest start first.
+func (x *Index) EachCovering(t time.Time, fn func(a *Activity)) {
+	// first activity that starts after t
+	i := sort.Search(len(x.activities), func(i int) bool {
+		return x.activities[i].StartDateTime.After(t)
+	})
+
+	for j := i - 1; j >= 0 && !x.maxEnd[j].Before(t); j-- {
+		if x.activities[j].EndDateTime.Before(t) {
+			continue
+		}
+		fn(&x.activities[j])
+	}
+}
+
+// Exact returns the activity that starts at start and ends at end, together with
+// the number of activities that do so.
+func (x *Index) Exact(start, end time.Time) (*Activity, int) {
+	i := sort.Search(len(x.activities), func(i int) bool {
+		return !x.activities[i].StartDateTime.Before(start)
+	})
+
+	var match *Activity
+	count := 0
+	for ; i < len(x.activities) && x.activities[i].StartDateTime.Equal(start); i++ {
+		if !x.activities[i].EndDateTime.Equal(end) {
+			continue
+		}
+		if match == nil {
+			match = &x.activities[i]
+		}
+		count++
+	}
+	return match, count
+}
@@ -19,6 +19,31 @@ type Activity struct {
 	EndDateTime        time.Time
 }
 
+// UserCount is the number of activities of a user.
+type UserCount struct {
+	UserID     string `json:"user_id"`
+	Activities int    `json:"activities"`
+}
+
+// ModeCount is the number of activities of a transportation mode.
+type ModeCount struct {
+	TransportationMode string `json:"transportation_mode"`
+	Activities         int    `json:"activities"`
+}
+
+// YearTotal is the number of activities or hours of the activities started in a year.
+type YearTotal struct {
+	Year  int `json:"year"`
+	Total int `json:"total"`
+}
+
+// TopMode is the transportation mode a user has most activities of.
+type TopMode struct {
+	UserID             string `json:"user_id"`
+	TransportationMode string `json:"transportation_mode"`
+	Activities         int    `json:"activities"`
+}
+
 type SortByDate []Activity
 
 func (a SortByDate) Len() int      { return len(a) }
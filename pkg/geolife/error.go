@@ -0,0 +1,31 @@
+package geolife
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParseError is a malformed line in a Geolife file. Line and Column are 1-based,
+// Column is 0 when the line as a whole is invalid.
+type ParseError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+var errEmptyMode = errors.New("empty transportation mode")
+
+func columnCountError(want, got int) error {
+	return fmt.Errorf("expected %d columns, got %d", want, got)
+}
@@ -0,0 +1,83 @@
+package geolife
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+)
+
+const labelDateTimeLayout = "2006/01/02 15:04:05"
+
+// LabelReader reads the activities of a labels.txt file one at a time.
+type LabelReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewLabelReader(r io.Reader) *LabelReader {
+	return &LabelReader{scanner: bufio.NewScanner(r)}
+}
+
+// Line returns the line number of the last line that was read.
+func (r *LabelReader) Line() int {
+	return r.line
+}
+
+// Read returns the next activity of the file. ID and UserID are left empty. At
+// the end of the file Read returns io.EOF, malformed lines are reported as a
+// *ParseError.
+func (r *LabelReader) Read() (activity.Activity, error) {
+	// skip header line
+	if r.line == 0 {
+		if !r.scanner.Scan() {
+			return activity.Activity{}, r.eof()
+		}
+		r.line++
+	}
+
+	for r.scanner.Scan() {
+		r.line++
+		row := strings.TrimSpace(r.scanner.Text())
+		if row == "" {
+			continue
+		}
+		return r.parse(row)
+	}
+	return activity.Activity{}, r.eof()
+}
+
+func (r *LabelReader) parse(row string) (activity.Activity, error) {
+	var a activity.Activity
+	cols := strings.Split(row, "\t")
+	if len(cols) != 3 {
+		return a, &ParseError{Line: r.line, Err: columnCountError(3, len(cols))}
+	}
+
+	startDate, err := time.Parse(labelDateTimeLayout, cols[0])
+	if err != nil {
+		return a, &ParseError{Line: r.line, Column: 1, Err: err}
+	}
+	endDate, err := time.Parse(labelDateTimeLayout, cols[1])
+	if err != nil {
+		return a, &ParseError{Line: r.line, Column: 2, Err: err}
+	}
+	mode := strings.TrimSpace(cols[2])
+	if mode == "" {
+		return a, &ParseError{Line: r.line, Column: 3, Err: errEmptyMode}
+	}
+
+	a.TransportationMode = mode
+	a.StartDateTime = startDate
+	a.EndDateTime = endDate
+	return a, nil
+}
+
+func (r *LabelReader) eof() error {
+	if err := r.scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
@@ -0,0 +1,128 @@
+// Package geolife reads the trajectory (.plt) and labels.txt files of the
+// Geolife dataset without depending on any database code.
+package geolife
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+// PLTHeaderLines is the number of header lines at the top of every .plt file.
+const PLTHeaderLines = 6
+
+const pltDateTimeLayout = "2006-01-02T15:04:05"
+
+// InvalidAltitude is the altitude the dataset uses when there is no valid altitude.
+const InvalidAltitude = -777
+
+// FeetToMeters converts the dataset's altitudes, which are in feet, to meters.
+const FeetToMeters = 0.3048
+
+// TrajectoryReader reads the trackpoints of a .plt file one at a time.
+type TrajectoryReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewTrajectoryReader(r io.Reader) *TrajectoryReader {
+	return &TrajectoryReader{scanner: bufio.NewScanner(r)}
+}
+
+// Line returns the line number of the last line that was read.
+func (r *TrajectoryReader) Line() int {
+	return r.line
+}
+
+// Read returns the next trackpoint of the file. UserID and ActivityID are left
+// empty. At the end of the file Read returns io.EOF, malformed lines are reported
+// as a *ParseError.
+func (r *TrajectoryReader) Read() (trackpoint.Trackpoint, error) {
+	for r.line < PLTHeaderLines {
+		if !r.scanner.Scan() {
+			return trackpoint.Trackpoint{}, r.eof()
+		}
+		r.line++
+	}
+
+	for r.scanner.Scan() {
+		r.line++
+		row := strings.TrimSpace(r.scanner.Text())
+		if row == "" {
+			continue
+		}
+		return r.parse(row)
+	}
+	return trackpoint.Trackpoint{}, r.eof()
+}
+
+func (r *TrajectoryReader) parse(row string) (trackpoint.Trackpoint, error) {
+	var t trackpoint.Trackpoint
+	cols := strings.Split(row, ",")
+	if len(cols) != 7 {
+		return t, r.error(0, columnCountError(7, len(cols)))
+	}
+
+	lat, err := strconv.ParseFloat(cols[0], 64)
+	if err != nil {
+		return t, r.error(1, err)
+	}
+	if lat < -90 || lat > 90 {
+		return t, r.errorf(1, "latitude %f out of range", lat)
+	}
+	lon, err := strconv.ParseFloat(cols[1], 64)
+	if err != nil {
+		return t, r.error(2, err)
+	}
+	if lon < -180 || lon > 180 {
+		return t, r.errorf(2, "longitude %f out of range", lon)
+	}
+	alt, err := strconv.ParseFloat(cols[3], 64) // alt is in some cases float
+	if err != nil {
+		return t, r.error(4, err)
+	}
+	days, err := strconv.ParseFloat(cols[4], 64)
+	if err != nil {
+		return t, r.error(5, err)
+	}
+	date, err := time.Parse(pltDateTimeLayout, cols[5]+"T"+cols[6])
+	if err != nil {
+		return t, r.error(6, err)
+	}
+
+	t.Lat = lat
+	t.Lon = lon
+	t.AltitudeRaw = alt
+	if alt != InvalidAltitude {
+		meters := alt * FeetToMeters
+		t.Altitude = &meters
+	}
+	t.DateDays = days
+	t.DateTime = date
+	return t, nil
+}
+
+func (r *TrajectoryReader) eof() error {
+	if err := r.scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func (r *TrajectoryReader) error(column int, err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		err = fmt.Errorf("invalid number %q", numErr.Num)
+	}
+	return &ParseError{Line: r.line, Column: column, Err: err}
+}
+
+func (r *TrajectoryReader) errorf(column int, format string, args ...interface{}) error {
+	return &ParseError{Line: r.line, Column: column, Err: fmt.Errorf(format, args...)}
+}
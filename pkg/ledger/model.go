@@ -0,0 +1,59 @@
+package ledger
+
+import "time"
+
+type Kind string
+
+const (
+	LABELS     Kind = "labels"
+	TRAJECTORY Kind = "trajectory"
+)
+
+type Status string
+
+const (
+	DONE    Status = "done"
+	SKIPPED Status = "skipped"
+	FAILED  Status = "failed"
+)
+
+// Entry describes one ingested dataset file. Path is relative to the dataset root.
+type Entry struct {
+	Path     string
+	UserID   string
+	Kind     Kind
+	Size     int64
+	ModTime  time.Time
+	Checksum string
+	RowCount int
+	Status   Status
+	Error    string
+	// SizePolicy and MaxPoints are the size policy and the maximum number of
+	// trackpoints a trajectory with more trackpoints than the maximum was loaded
+	// with. SizePolicy is empty for the other files.
+	SizePolicy string
+	MaxPoints  int
+}
+
+// Finished reports whether the file does not have to be loaded again.
+func (e Entry) Finished() bool {
+	return e.Status == DONE || e.Status == SKIPPED
+}
+
+// SameLimits reports whether loading the file again with sizePolicy and
+// maxPoints gives the rows it was loaded with.
+func (e Entry) SameLimits(sizePolicy string, maxPoints int) bool {
+	if e.Kind != TRAJECTORY {
+		return true
+	}
+	if e.SizePolicy == "" {
+		// skipped files recorded without their limits are loaded again
+		return e.Status != SKIPPED && e.RowCount <= maxPoints
+	}
+	return e.SizePolicy == sizePolicy && e.MaxPoints == maxPoints
+}
+
+// SameStat reports whether size and modification time are unchanged since e was recorded.
+func (e Entry) SameStat(other Entry) bool {
+	return e.Size == other.Size && e.ModTime.Equal(other.ModTime)
+}
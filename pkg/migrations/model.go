@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+)
+
+// Migration is one versioned step of the schema. Up and Down are executed one
+// statement at a time, in order.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+	// Tables are the tables the migration creates that databases created before
+	// the migrations already have. The migrator refuses to apply it when one of
+	// them exists, since it would record the old table as migrated.
+	Tables []string
+}
+
+// Status tells whether a migration has been applied to the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Dialect is what the migrator has to know about a database engine to maintain
+// the schema_migrations table.
+type Dialect struct {
+	// Timestamp is the column type of applied_at.
+	Timestamp string
+	// Numbered is set for engines that take $1, $2, ... instead of ? placeholders.
+	Numbered bool
+	// TableCount counts the tables of the database named by its argument.
+	TableCount string
+}
+
+var (
+	DialectMySQL = Dialect{
+		Timestamp:  "DATETIME",
+		TableCount: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?",
+	}
+	DialectSQLite = Dialect{
+		Timestamp:  "DATETIME",
+		TableCount: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?",
+	}
+	DialectPostgres = Dialect{
+		Timestamp:  "TIMESTAMPTZ",
+		Numbered:   true,
+		TableCount: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1",
+	}
+)
+
+// placeholder returns the placeholder of the nth query argument, counting from 1.
+func (d Dialect) placeholder(n int) string {
+	if d.Numbered {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
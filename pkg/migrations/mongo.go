@@ -0,0 +1,52 @@
+package migrations
+
+// Mongo is the schema of the MongoDB document store. Its statements are database
+// commands in extended JSON, run by the migrator of pkg/store/mongo. Activities
+// embed their trackpoints, and the trackpoints of a trajectory that fall into no
+// activity are stored in the trajectories collection. Counters holds the last
+// activity and trackpoint ids handed out. Transportation modes are indexed
+// case-insensitively, like with the MySQL collation.
+var Mongo = []Migration{
+	{
+		Version: 1,
+		Name:    "create_users",
+		Up:      []string{`{"create": "users"}`},
+		Down:    []string{`{"drop": "users"}`},
+	},
+	{
+		Version: 2,
+		Name:    "create_activities",
+		Up: []string{
+			`{"create": "activities"}`,
+			`{"createIndexes": "activities", "indexes": [
+				{"key": {"user_id": 1, "start_date_time": 1}, "name": "user_start"},
+				{"key": {"transportation_mode": 1, "user_id": 1}, "name": "tran_user", "collation": {"locale": "en", "strength": 2}}
+			]}`,
+		},
+		Down: []string{`{"drop": "activities"}`},
+	},
+	{
+		Version: 3,
+		Name:    "create_trajectories",
+		Up: []string{
+			`{"create": "trajectories"}`,
+			`{"createIndexes": "trajectories", "indexes": [{"key": {"user_id": 1, "start_date_time": 1}, "name": "user_start"}]}`,
+		},
+		Down: []string{`{"drop": "trajectories"}`},
+	},
+	{
+		Version: 4,
+		Name:    "create_counters",
+		Up:      []string{`{"create": "counters"}`},
+		Down:    []string{`{"drop": "counters"}`},
+	},
+	{
+		Version: 5,
+		Name:    "create_ingestion_ledger",
+		Up: []string{
+			`{"create": "ingestion_ledger"}`,
+			`{"createIndexes": "ingestion_ledger", "indexes": [{"key": {"user_id": 1}, "name": "ledger_user"}]}`,
+		},
+		Down: []string{`{"drop": "ingestion_ledger"}`},
+	},
+}
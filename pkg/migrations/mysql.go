@@ -0,0 +1,140 @@
+package migrations
+
+// MySQL is the schema of the MySQL database. New migrations are appended with the
+// next version, applied migrations are never changed. User, Activity and
+// Trackpoint were created by the loader itself before there were migrations, with
+// an integer altitude and without altitude_raw, so versions 1 to 3 refuse to
+// adopt them.
+var MySQL = []Migration{
+	{
+		Version: 1,
+		Name:    "create_user",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS User (
+				id VARCHAR(30) NOT NULL PRIMARY KEY,
+				has_labels BOOL
+			)`,
+		},
+		Down:   []string{"DROP TABLE User"},
+		Tables: []string{"User"},
+	},
+	{
+		Version: 2,
+		Name:    "create_activity",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS Activity (
+				id INT AUTO_INCREMENT NOT NULL PRIMARY KEY,
+				user_id VARCHAR(30),
+				transportation_mode VARCHAR(30),
+				start_date_time DATETIME,
+				end_date_time DATETIME,
+				FOREIGN KEY (user_id) REFERENCES User(id),
+				INDEX tran_user (transportation_mode, user_id)
+			)`,
+		},
+		Down:   []string{"DROP TABLE Activity"},
+		Tables: []string{"Activity"},
+	},
+	{
+		Version: 3,
+		Name:    "create_trackpoint",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS Trackpoint (
+				id INT AUTO_INCREMENT NOT NULL PRIMARY KEY,
+				activity_id INT,
+				user_id VARCHAR(30),
+				lat DOUBLE,
+				lon DOUBLE,
+				altitude DOUBLE,
+				altitude_raw DOUBLE,
+				date_days DOUBLE,
+				date_time DATETIME,
+				FOREIGN KEY(activity_id) REFERENCES Activity(id),
+				FOREIGN KEY(user_id) REFERENCES User(id),
+				INDEX act_date (date_time, activity_id),
+				INDEX act (activity_id),
+				INDEX coords (lat, lon)
+			)`,
+		},
+		Down:   []string{"DROP TABLE Trackpoint"},
+		Tables: []string{"Trackpoint"},
+	},
+	{
+		Version: 4,
+		Name:    "create_user_activity_count_view",
+		Up:      []string{"CREATE OR REPLACE VIEW UserActivityCount AS SELECT user_id, COUNT(*) as count FROM Activity GROUP BY user_id"},
+		Down:    []string{"DROP VIEW UserActivityCount"},
+	},
+	{
+		Version: 5,
+		Name:    "create_ingestion_ledger",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS IngestionLedger (
+				path VARCHAR(255) NOT NULL PRIMARY KEY,
+				user_id VARCHAR(30) NOT NULL,
+				kind VARCHAR(16) NOT NULL,
+				size BIGINT NOT NULL,
+				mod_time BIGINT NOT NULL,
+				checksum CHAR(64) NOT NULL,
+				row_count INT NOT NULL,
+				status VARCHAR(16) NOT NULL,
+				error TEXT,
+				updated_at DATETIME NOT NULL,
+				INDEX ledger_user (user_id)
+			)`,
+		},
+		Down: []string{"DROP TABLE IngestionLedger"},
+	},
+	{
+		Version: 6,
+		Name:    "add_altitude_and_transportation_mode_indexes",
+		Up: []string{
+			"CREATE INDEX altitude ON Trackpoint(altitude)",
+			"CREATE INDEX transportation_mode ON Activity(transportation_mode)",
+		},
+		Down: []string{
+			"DROP INDEX transportation_mode ON Activity",
+			"DROP INDEX altitude ON Trackpoint",
+		},
+	},
+	// the routes of users moving between shards, which only MySQL servers are, so
+	// the other engines have no version 7
+	{
+		Version: 7,
+		Name:    "create_shard_route",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS ShardRoute (
+				user_id VARCHAR(30) NOT NULL PRIMARY KEY,
+				source VARCHAR(64) NOT NULL,
+				target VARCHAR(64) NOT NULL,
+				state VARCHAR(16) NOT NULL,
+				updated_at DATETIME NOT NULL
+			)`,
+		},
+		Down: []string{"DROP TABLE ShardRoute"},
+	},
+	// a spatial index needs a NOT NULL column, so the column is added, backfilled
+	// from lat and lon and then made NOT NULL
+	{
+		Version: 8,
+		Name:    "add_trackpoint_location",
+		Up: []string{
+			"ALTER TABLE Trackpoint ADD COLUMN location POINT SRID 4326",
+			"UPDATE Trackpoint SET location = ST_PointFromText(CONCAT('POINT(', lat, ' ', lon, ')'), 4326, 'axis-order=lat-long')",
+			"ALTER TABLE Trackpoint MODIFY location POINT SRID 4326 NOT NULL",
+			"CREATE SPATIAL INDEX location ON Trackpoint(location)",
+		},
+		Down: []string{"ALTER TABLE Trackpoint DROP COLUMN location"},
+	},
+	// the size limits that a long trajectory was loaded with, so that it is
+	// loaded again when they change
+	{
+		Version: 9,
+		Name:    "add_ledger_size_limits",
+		Up: []string{
+			"ALTER TABLE IngestionLedger ADD COLUMN size_policy VARCHAR(16) NOT NULL DEFAULT ''",
+			"ALTER TABLE IngestionLedger ADD COLUMN max_points INT NOT NULL DEFAULT 0",
+		},
+		Down: []string{"ALTER TABLE IngestionLedger DROP COLUMN max_points, DROP COLUMN size_policy"},
+	},
+}
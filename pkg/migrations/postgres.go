@@ -0,0 +1,107 @@
+package migrations
+
+// Postgres is the schema of the PostgreSQL database. Its versions mirror the
+// MySQL migrations. Trackpoints are stored as a PostGIS geography point in WGS 84
+// with a GiST index instead of lat and lon columns. Rolling back
+// create_trackpoint keeps the postgis extension, since other schemas may use it.
+var Postgres = []Migration{
+	{
+		Version: 1,
+		Name:    "create_user",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS users (
+				id VARCHAR(30) NOT NULL PRIMARY KEY,
+				has_labels BOOLEAN
+			)`,
+		},
+		Down: []string{"DROP TABLE users"},
+	},
+	{
+		Version: 2,
+		Name:    "create_activity",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS activity (
+				id SERIAL PRIMARY KEY,
+				user_id VARCHAR(30) REFERENCES users(id),
+				transportation_mode VARCHAR(30),
+				start_date_time TIMESTAMP,
+				end_date_time TIMESTAMP
+			)`,
+			"CREATE INDEX IF NOT EXISTS tran_user ON activity(transportation_mode, user_id)",
+		},
+		Down: []string{"DROP TABLE activity"},
+	},
+	{
+		Version: 3,
+		Name:    "create_trackpoint",
+		Up: []string{
+			"CREATE EXTENSION IF NOT EXISTS postgis",
+			`CREATE TABLE IF NOT EXISTS trackpoint (
+				id BIGSERIAL PRIMARY KEY,
+				activity_id INT REFERENCES activity(id),
+				user_id VARCHAR(30) REFERENCES users(id),
+				location geography(Point, 4326) NOT NULL,
+				altitude DOUBLE PRECISION,
+				altitude_raw DOUBLE PRECISION,
+				date_days DOUBLE PRECISION,
+				date_time TIMESTAMP
+			)`,
+			"CREATE INDEX IF NOT EXISTS act_date ON trackpoint(date_time, activity_id)",
+			"CREATE INDEX IF NOT EXISTS act ON trackpoint(activity_id)",
+			"CREATE INDEX IF NOT EXISTS trackpoint_user ON trackpoint(user_id)",
+			"CREATE INDEX IF NOT EXISTS location ON trackpoint USING GIST(location)",
+		},
+		Down: []string{"DROP TABLE trackpoint"},
+	},
+	{
+		Version: 4,
+		Name:    "create_user_activity_count_view",
+		Up:      []string{"CREATE OR REPLACE VIEW user_activity_count AS SELECT user_id, COUNT(*) AS count FROM activity GROUP BY user_id"},
+		Down:    []string{"DROP VIEW user_activity_count"},
+	},
+	{
+		Version: 5,
+		Name:    "create_ingestion_ledger",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS ingestion_ledger (
+				path VARCHAR(255) NOT NULL PRIMARY KEY,
+				user_id VARCHAR(30) NOT NULL,
+				kind VARCHAR(16) NOT NULL,
+				size BIGINT NOT NULL,
+				mod_time BIGINT NOT NULL,
+				checksum CHAR(64) NOT NULL,
+				row_count INT NOT NULL,
+				status VARCHAR(16) NOT NULL,
+				error TEXT,
+				updated_at TIMESTAMPTZ NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS ledger_user ON ingestion_ledger(user_id)",
+		},
+		Down: []string{"DROP TABLE ingestion_ledger"},
+	},
+	{
+		Version: 6,
+		Name:    "add_altitude_and_transportation_mode_indexes",
+		Up: []string{
+			"CREATE INDEX altitude ON trackpoint(altitude)",
+			// the activity queries group and compare lower(transportation_mode), so
+			// that modes are case-insensitive like with the MySQL collation
+			"CREATE INDEX transportation_mode ON activity(lower(transportation_mode))",
+		},
+		Down: []string{
+			"DROP INDEX transportation_mode",
+			"DROP INDEX altitude",
+		},
+	},
+	// the size limits that a long trajectory was loaded with, so that it is
+	// loaded again when they change
+	{
+		Version: 9,
+		Name:    "add_ledger_size_limits",
+		Up: []string{
+			"ALTER TABLE ingestion_ledger ADD COLUMN size_policy VARCHAR(16) NOT NULL DEFAULT ''",
+			"ALTER TABLE ingestion_ledger ADD COLUMN max_points INT NOT NULL DEFAULT 0",
+		},
+		Down: []string{"ALTER TABLE ingestion_ledger DROP COLUMN max_points, DROP COLUMN size_policy"},
+	},
+}
@@ -0,0 +1,176 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+func New(db *sql.DB, dialect Dialect, migrations []Migration) (*Migrator, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", sorted[i].Version)
+		}
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: sorted}, nil
+}
+
+// Migrator applies and rolls back migrations and records the applied versions in
+// the schema_migrations table, which it creates when needed. MySQL commits DDL
+// statements implicitly, so a migration that fails halfway is not rolled back and
+// has to be fixed by hand.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+func (m *Migrator) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at ` + m.dialect.Timestamp + ` NOT NULL
+	)`
+
+	_, err := m.db.Exec(query)
+	return err
+}
+
+// Up applies every pending migration in order and returns the applied ones.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.checkTables(migration); err != nil {
+			return done, err
+		}
+		if err := m.exec(migration, migration.Up); err != nil {
+			return done, err
+		}
+		query := fmt.Sprintf("INSERT INTO schema_migrations(version, name, applied_at) VALUES( %s, %s, %s )", m.dialect.placeholder(1), m.dialect.placeholder(2), m.dialect.placeholder(3))
+		_, err := m.db.ExecContext(context.TODO(), query, migration.Version, migration.Name, time.Now())
+		if err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the latest applied migration. It returns nil if no migration
+// is applied.
+func (m *Migrator) Down() (*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.exec(migration, migration.Down); err != nil {
+			return nil, err
+		}
+		_, err := m.db.ExecContext(context.TODO(), "DELETE FROM schema_migrations WHERE version = "+m.dialect.placeholder(1), migration.Version)
+		if err != nil {
+			return nil, err
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+// Reset rolls back every applied migration and returns the rolled back ones.
+func (m *Migrator) Reset() ([]Migration, error) {
+	var done []Migration
+	for {
+		migration, err := m.Down()
+		if err != nil {
+			return done, err
+		}
+		if migration == nil {
+			return done, nil
+		}
+		done = append(done, *migration)
+	}
+}
+
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if err := m.CreateTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(context.TODO(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// checkTables returns an error if one of the tables of migration exists while
+// the migration is not applied, which is the case for databases created before
+// the schema was migrated.
+func (m *Migrator) checkTables(migration Migration) error {
+	for _, table := range migration.Tables {
+		var count int
+		if err := m.db.QueryRowContext(context.TODO(), m.dialect.TableCount, table).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("migration %d %s: table %s exists but the migration is not recorded in schema_migrations; the database was created before its schema was migrated, drop its tables and load the dataset again", migration.Version, migration.Name, table)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) exec(migration Migration, statements []string) error {
+	for _, stmt := range statements {
+		if _, err := m.db.ExecContext(context.TODO(), stmt); err != nil {
+			return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestUpRefusesUnmigratedTables(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the table as the loader created it before there were migrations
+	if _, err := db.Exec("CREATE TABLE Trackpoint (id INTEGER PRIMARY KEY, altitude INT)"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(db, DialectSQLite, []Migration{
+		{
+			Version: 1,
+			Name:    "create_trackpoint",
+			Up:      []string{"CREATE TABLE IF NOT EXISTS Trackpoint (id INTEGER PRIMARY KEY, altitude DOUBLE, altitude_raw DOUBLE)"},
+			Down:    []string{"DROP TABLE Trackpoint"},
+			Tables:  []string{"Trackpoint"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(); err == nil || !strings.Contains(err.Error(), "table Trackpoint exists") {
+		t.Fatalf("Up() = %v, want an error about the existing table", err)
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].Applied {
+		t.Error("create_trackpoint is recorded as applied")
+	}
+
+	// once the old table is dropped the migration creates it
+	if _, err := db.Exec("DROP TABLE Trackpoint"); err != nil {
+		t.Fatal(err)
+	}
+	done, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 {
+		t.Errorf("Up() applied %d migrations, want 1", len(done))
+	}
+}
@@ -0,0 +1,123 @@
+package migrations
+
+// SQLite is the schema of the SQLite database. Its versions mirror the MySQL
+// migrations. Transportation modes compare case-insensitively, like they do with
+// the default MySQL collation.
+var SQLite = []Migration{
+	{
+		Version: 1,
+		Name:    "create_user",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS User (
+				id VARCHAR(30) NOT NULL PRIMARY KEY,
+				has_labels BOOL
+			)`,
+		},
+		Down: []string{"DROP TABLE User"},
+	},
+	{
+		Version: 2,
+		Name:    "create_activity",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS Activity (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id VARCHAR(30) REFERENCES User(id),
+				transportation_mode VARCHAR(30) COLLATE NOCASE,
+				start_date_time DATETIME,
+				end_date_time DATETIME
+			)`,
+			"CREATE INDEX IF NOT EXISTS tran_user ON Activity(transportation_mode, user_id)",
+		},
+		Down: []string{"DROP TABLE Activity"},
+	},
+	{
+		Version: 3,
+		Name:    "create_trackpoint",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS Trackpoint (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				activity_id INTEGER REFERENCES Activity(id),
+				user_id VARCHAR(30) REFERENCES User(id),
+				lat DOUBLE,
+				lon DOUBLE,
+				altitude DOUBLE,
+				altitude_raw DOUBLE,
+				date_days DOUBLE,
+				date_time DATETIME
+			)`,
+			"CREATE INDEX IF NOT EXISTS act_date ON Trackpoint(date_time, activity_id)",
+			"CREATE INDEX IF NOT EXISTS act ON Trackpoint(activity_id)",
+			"CREATE INDEX IF NOT EXISTS coords ON Trackpoint(lat, lon)",
+			"CREATE INDEX IF NOT EXISTS trackpoint_user ON Trackpoint(user_id)",
+		},
+		Down: []string{"DROP TABLE Trackpoint"},
+	},
+	{
+		Version: 4,
+		Name:    "create_user_activity_count_view",
+		Up:      []string{"CREATE VIEW IF NOT EXISTS UserActivityCount AS SELECT user_id, COUNT(*) as count FROM Activity GROUP BY user_id"},
+		Down:    []string{"DROP VIEW UserActivityCount"},
+	},
+	{
+		Version: 5,
+		Name:    "create_ingestion_ledger",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS IngestionLedger (
+				path VARCHAR(255) NOT NULL PRIMARY KEY,
+				user_id VARCHAR(30) NOT NULL,
+				kind VARCHAR(16) NOT NULL,
+				size BIGINT NOT NULL,
+				mod_time BIGINT NOT NULL,
+				checksum CHAR(64) NOT NULL,
+				row_count INT NOT NULL,
+				status VARCHAR(16) NOT NULL,
+				error TEXT,
+				updated_at DATETIME NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS ledger_user ON IngestionLedger(user_id)",
+		},
+		Down: []string{"DROP TABLE IngestionLedger"},
+	},
+	{
+		Version: 6,
+		Name:    "add_altitude_and_transportation_mode_indexes",
+		Up: []string{
+			"CREATE INDEX altitude ON Trackpoint(altitude)",
+			"CREATE INDEX transportation_mode ON Activity(transportation_mode)",
+		},
+		Down: []string{
+			"DROP INDEX transportation_mode",
+			"DROP INDEX altitude",
+		},
+	},
+	// the size limits that a long trajectory was loaded with, so that it is
+	// loaded again when they change
+	{
+		Version: 9,
+		Name:    "add_ledger_size_limits",
+		Up: []string{
+			"ALTER TABLE IngestionLedger ADD COLUMN size_policy VARCHAR(16) NOT NULL DEFAULT ''",
+			"ALTER TABLE IngestionLedger ADD COLUMN max_points INT NOT NULL DEFAULT 0",
+		},
+		// the bundled SQLite cannot drop columns, so the table is copied without them
+		Down: []string{`
+			CREATE TABLE IngestionLedger_old (
+				path VARCHAR(255) NOT NULL PRIMARY KEY,
+				user_id VARCHAR(30) NOT NULL,
+				kind VARCHAR(16) NOT NULL,
+				size BIGINT NOT NULL,
+				mod_time BIGINT NOT NULL,
+				checksum CHAR(64) NOT NULL,
+				row_count INT NOT NULL,
+				status VARCHAR(16) NOT NULL,
+				error TEXT,
+				updated_at DATETIME NOT NULL
+			)`,
+			`INSERT INTO IngestionLedger_old SELECT path, user_id, kind, size, mod_time, checksum, row_count, status, error, updated_at
+				FROM IngestionLedger`,
+			"DROP TABLE IngestionLedger",
+			"ALTER TABLE IngestionLedger_old RENAME TO IngestionLedger",
+			"CREATE INDEX IF NOT EXISTS ledger_user ON IngestionLedger(user_id)",
+		},
+	},
+}
@@ -0,0 +1,89 @@
+// Package progress tracks and reports the progress of a dataset load.
+package progress
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Counter is shared by the load goroutines. All methods are safe for concurrent use.
+type Counter struct {
+	start       time.Time
+	usersTotal  int64
+	usersWalked int64
+	usersDone   int64
+	filesTotal  int64
+	filesDone   int64
+	trackpoints int64
+}
+
+func NewCounter(usersTotal int) *Counter {
+	return &Counter{start: time.Now(), usersTotal: int64(usersTotal)}
+}
+
+// UserWalked adds the pending files of a user to the total.
+func (c *Counter) UserWalked(files int) {
+	atomic.AddInt64(&c.filesTotal, int64(files))
+	atomic.AddInt64(&c.usersWalked, 1)
+}
+
+func (c *Counter) UserDone() {
+	atomic.AddInt64(&c.usersDone, 1)
+}
+
+// FilesDone counts n files that were loaded, skipped or failed.
+func (c *Counter) FilesDone(n int) {
+	atomic.AddInt64(&c.filesDone, int64(n))
+}
+
+func (c *Counter) AddTrackpoints(n int) {
+	atomic.AddInt64(&c.trackpoints, int64(n))
+}
+
+// Snapshot is the state of a Counter at one point in time.
+type Snapshot struct {
+	UsersTotal  int
+	UsersDone   int
+	FilesTotal  int
+	FilesDone   int
+	Trackpoints int
+	Elapsed     time.Duration
+	// FilesKnown is true once every user has been walked and FilesTotal is final.
+	FilesKnown bool
+}
+
+func (c *Counter) Snapshot() Snapshot {
+	usersTotal := atomic.LoadInt64(&c.usersTotal)
+	return Snapshot{
+		UsersTotal:  int(usersTotal),
+		UsersDone:   int(atomic.LoadInt64(&c.usersDone)),
+		FilesTotal:  int(atomic.LoadInt64(&c.filesTotal)),
+		FilesDone:   int(atomic.LoadInt64(&c.filesDone)),
+		Trackpoints: int(atomic.LoadInt64(&c.trackpoints)),
+		Elapsed:     time.Since(c.start),
+		FilesKnown:  atomic.LoadInt64(&c.usersWalked) == usersTotal,
+	}
+}
+
+// RowsPerSecond returns the inserted trackpoints per second.
+func (s Snapshot) RowsPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Trackpoints) / s.Elapsed.Seconds()
+}
+
+// ETA estimates the remaining time from the share of finished files, or of
+// finished users while not all files are known yet. It returns false when there
+// is nothing to estimate from.
+func (s Snapshot) ETA() (time.Duration, bool) {
+	done, total := s.UsersDone, s.UsersTotal
+	if s.FilesKnown {
+		done, total = s.FilesDone, s.FilesTotal
+	}
+	if done == 0 || total == 0 {
+		return 0, false
+	}
+	remaining := float64(total-done) / float64(done)
+	return time.Duration(float64(s.Elapsed) * remaining), true
+}
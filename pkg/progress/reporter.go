@@ -0,0 +1,95 @@
+package progress
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	ttyInterval = 500 * time.Millisecond
+	logInterval = 10 * time.Second
+)
+
+// Reporter periodically renders a Counter. On a terminal it redraws a single
+// line, otherwise it writes a line every logInterval.
+type Reporter struct {
+	counter *Counter
+	out     *os.File
+	tty     bool
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewReporter(counter *Counter, out *os.File) *Reporter {
+	return &Reporter{
+		counter: counter,
+		out:     out,
+		tty:     isTerminal(out),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (r *Reporter) Start() {
+	interval := logInterval
+	if r.tty {
+		interval = ttyInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.render()
+			case <-r.stop:
+				r.render()
+				if r.tty {
+					fmt.Fprintln(r.out)
+				}
+				close(r.stopped)
+				return
+			}
+		}
+	}()
+}
+
+// Stop renders the final state and stops the reporter.
+func (r *Reporter) Stop() {
+	close(r.stop)
+	<-r.stopped
+}
+
+func (r *Reporter) render() {
+	line := Format(r.counter.Snapshot())
+	if r.tty {
+		// return to the start of the line and clear what is left of the last one
+		fmt.Fprintf(r.out, "\r%s\x1b[K", line)
+		return
+	}
+	fmt.Fprintln(r.out, line)
+}
+
+// Format renders a snapshot as a single line.
+func Format(s Snapshot) string {
+	files := fmt.Sprintf("%d/%d", s.FilesDone, s.FilesTotal)
+	if !s.FilesKnown {
+		files += "+"
+	}
+	eta := "unknown"
+	if d, ok := s.ETA(); ok {
+		eta = d.Round(time.Second).String()
+	}
+	return fmt.Sprintf("users %d/%d  files %s  trackpoints %d  %.0f rows/s  ETA %s",
+		s.UsersDone, s.UsersTotal, files, s.Trackpoints, s.RowsPerSecond(), eta)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,155 @@
+// Package replica sends read-only queries to MySQL read replicas, one after the
+// other, and to the primary when no replica is close enough behind it.
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errNotReplicating = errors.New("replica: replication is not running")
+
+// defaultCheckInterval is how long the lag of a replica is trusted before it is
+// checked again.
+const defaultCheckInterval = 5 * time.Second
+
+type Options struct {
+	// MaxLag is how far a replica may be behind the primary to be read from.
+	// The lag is not checked if it is 0.
+	MaxLag time.Duration
+	// CheckInterval is how often the lag of a replica is checked, every 5
+	// seconds by default.
+	CheckInterval time.Duration
+}
+
+func New(primary *sql.DB, replicas []*sql.DB, opts Options) *Set {
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultCheckInterval
+	}
+
+	s := &Set{primary: primary, opts: opts}
+	for _, db := range replicas {
+		s.replicas = append(s.replicas, &replica{db: db})
+	}
+	return s
+}
+
+// Set is a primary with its read replicas.
+type Set struct {
+	primary  *sql.DB
+	replicas []*replica
+	opts     Options
+	// next is the replica the next read starts at
+	next uint32
+}
+
+type replica struct {
+	db      *sql.DB
+	mu      sync.Mutex
+	checked time.Time
+	behind  bool
+}
+
+// Primary returns the database all writes go to.
+func (s *Set) Primary() *sql.DB {
+	return s.primary
+}
+
+// Reader returns the database for the next read-only query: the next replica
+// that is not too far behind, or the primary if there is none.
+func (s *Set) Reader() *sql.DB {
+	if len(s.replicas) == 0 {
+		return s.primary
+	}
+
+	start := int(atomic.AddUint32(&s.next, 1))
+	for i := range s.replicas {
+		r := s.replicas[(start+i)%len(s.replicas)]
+		if s.opts.MaxLag == 0 || !r.isBehind(s.opts) {
+			return r.db
+		}
+	}
+	return s.primary
+}
+
+// Close closes the replicas. The primary is closed by its owner.
+func (s *Set) Close() error {
+	var first error
+	for _, r := range s.replicas {
+		if err := r.db.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// isBehind reports whether the replica was more than opts.MaxLag behind the
+// primary when it was last checked, and checks it again if that was more than
+// opts.CheckInterval ago.
+func (r *replica) isBehind(opts Options) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) < opts.CheckInterval {
+		return r.behind
+	}
+
+	lag, err := Lag(r.db)
+	r.behind = err != nil || lag > opts.MaxLag
+	r.checked = time.Now()
+	return r.behind
+}
+
+// Lag returns how far the MySQL replica db is behind its source, as reported by
+// SHOW REPLICA STATUS, or SHOW SLAVE STATUS before MySQL 8.0.22. A server that
+// is not a replica has no lag. It fails if replication is not running, since
+// the lag is unknown then.
+func Lag(db *sql.DB) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if !values[i].Valid {
+			return 0, errNotReplicating
+		}
+		seconds, err := time.ParseDuration(values[i].String + "s")
+		if err != nil {
+			return 0, err
+		}
+		return seconds, nil
+	}
+	return 0, errNotReplicating
+}
@@ -0,0 +1,261 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+type activityStore struct {
+	store *Store
+}
+
+func (a *activityStore) GetActivitiesForUser(userID string) ([]activity.Activity, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	activities := make([]activity.Activity, len(a.store.activities[userID]))
+	copy(activities, a.store.activities[userID])
+	sort.Stable(activity.SortByDate(activities))
+	return activities, nil
+}
+
+func (a *activityStore) DeleteForUser(userID string) error {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+	delete(a.store.activities, userID)
+	return nil
+}
+
+func (a *activityStore) GetCount() (int, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	count := 0
+	for _, activities := range a.store.activities {
+		count += len(activities)
+	}
+	return count, nil
+}
+
+// AverageActivitesPerUser averages over the users with at least one activity.
+func (a *activityStore) AverageActivitesPerUser() (float64, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	users, count := 0, 0
+	for _, activities := range a.store.activities {
+		if len(activities) > 0 {
+			users++
+			count += len(activities)
+		}
+	}
+	if users == 0 {
+		return 0, nil
+	}
+	return float64(count) / float64(users), nil
+}
+
+func (a *activityStore) GetUsersActivityCount(limit int) ([]activity.UserCount, error) {
+	a.store.mu.RLock()
+	counts := make(map[string]int)
+	for userID, activities := range a.store.activities {
+		if len(activities) > 0 {
+			counts[userID] = len(activities)
+		}
+	}
+	a.store.mu.RUnlock()
+
+	userIDs := sortCounts(counts)
+	if limit != -1 && len(userIDs) > limit {
+		userIDs = userIDs[:limit]
+	}
+	users := make([]activity.UserCount, len(userIDs))
+	for i, userID := range userIDs {
+		users[i] = activity.UserCount{UserID: userID, Activities: counts[userID]}
+	}
+	return users, nil
+}
+
+// GetTransportationCounts groups modes that only differ in case under the
+// spelling seen first.
+func (a *activityStore) GetTransportationCounts() ([]activity.ModeCount, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	spelling := make(map[string]string)
+	counts := make(map[string]int)
+	a.store.eachActivity(func(act activity.Activity) {
+		key := strings.ToLower(act.TransportationMode)
+		if _, ok := spelling[key]; !ok {
+			spelling[key] = act.TransportationMode
+		}
+		counts[spelling[key]]++
+	})
+
+	var modes []activity.ModeCount
+	for _, mode := range sortCounts(counts) {
+		modes = append(modes, activity.ModeCount{TransportationMode: mode, Activities: counts[mode]})
+	}
+	return modes, nil
+}
+
+func (a *activityStore) YearWithMostActivites() (int, int, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	counts := make(map[int]int)
+	a.store.eachActivity(func(act activity.Activity) {
+		counts[act.StartDateTime.Year()]++
+	})
+	year, count := maxByYear(counts)
+	return year, count, nil
+}
+
+// YearWithMostHours truncates the duration of each activity to whole hours
+// before summing, like TIMESTAMPDIFF does in the MySQL query.
+func (a *activityStore) YearWithMostHours() (int, int, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	hours := make(map[int]int)
+	a.store.eachActivity(func(act activity.Activity) {
+		hours[act.StartDateTime.Year()] += int(act.EndDateTime.Sub(act.StartDateTime).Hours())
+	})
+	year, count := maxByYear(hours)
+	return year, count, nil
+}
+
+func (a *activityStore) GetActivityCountsByYear() ([]activity.YearTotal, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	counts := make(map[int]int)
+	a.store.eachActivity(func(act activity.Activity) {
+		counts[act.StartDateTime.Year()]++
+	})
+	return sortYears(counts), nil
+}
+
+func (a *activityStore) GetHoursByYear() ([]activity.YearTotal, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	hours := make(map[int]int)
+	a.store.eachActivity(func(act activity.Activity) {
+		hours[act.StartDateTime.Year()] += int(act.EndDateTime.Sub(act.StartDateTime).Hours())
+	})
+	return sortYears(hours), nil
+}
+
+func (a *activityStore) GetDistanceByUser(userID, transportationMode string, year int) (float64, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	walks := make(map[int]bool)
+	for _, act := range a.store.activities[userID] {
+		if strings.EqualFold(act.TransportationMode, transportationMode) && act.StartDateTime.Year() == year {
+			walks[act.ID] = true
+		}
+	}
+
+	var points []trackpoint.Trackpoint
+	for _, p := range a.store.trackpoints[userID] {
+		if walks[activityID(p)] {
+			points = append(points, p)
+		}
+	}
+	sortByDate(points)
+
+	distance := 0.0
+	for i := 1; i < len(points); i++ {
+		if activityID(points[i]) == activityID(points[i-1]) {
+			distance += activity.Distance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+		}
+	}
+	return distance, nil
+}
+
+// GetTopTransportationByUsers returns the most used mode of every user with
+// activities, ordered by user id. Modes are counted ignoring case, under the
+// first spelling of the user, and ties go to the mode that sorts first.
+func (a *activityStore) GetTopTransportationByUsers() ([]activity.TopMode, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	var userIDs []string
+	for userID, activities := range a.store.activities {
+		if _, ok := a.store.users[userID]; ok && len(activities) > 0 {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Strings(userIDs)
+
+	var top []activity.TopMode
+	for _, userID := range userIDs {
+		spelling := make(map[string]string)
+		modeCounts := make(map[string]int)
+		for _, act := range a.store.activities[userID] {
+			key := strings.ToLower(act.TransportationMode)
+			if _, ok := spelling[key]; !ok {
+				spelling[key] = act.TransportationMode
+			}
+			modeCounts[spelling[key]]++
+		}
+		mode := sortCounts(modeCounts)[0]
+		top = append(top, activity.TopMode{UserID: userID, TransportationMode: mode, Activities: modeCounts[mode]})
+	}
+	return top, nil
+}
+
+// eachActivity calls fn for every activity. The caller has to hold the lock.
+func (s *Store) eachActivity(fn func(a activity.Activity)) {
+	for _, activities := range s.activities {
+		for _, a := range activities {
+			fn(a)
+		}
+	}
+}
+
+// sortCounts returns the keys of counts by count, highest first, and then by
+// key.
+func sortCounts(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// sortYears returns the years and totals of totals by year.
+func sortYears(totals map[int]int) []activity.YearTotal {
+	years := make([]activity.YearTotal, 0, len(totals))
+	for year, total := range totals {
+		years = append(years, activity.YearTotal{Year: year, Total: total})
+	}
+	sort.Slice(years, func(i, j int) bool {
+		return years[i].Year < years[j].Year
+	})
+	return years
+}
+
+// maxByYear returns the year with the highest value, the earliest on ties.
+func maxByYear(values map[int]int) (int, int) {
+	year, max := 0, 0
+	first := true
+	for y, v := range values {
+		if first || v > max || (v == max && y < year) {
+			year, max = y, v
+			first = false
+		}
+	}
+	return year, max
+}
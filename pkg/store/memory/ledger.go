@@ -0,0 +1,54 @@
+package memory
+
+import "github.com/spacycoder/db_mysql/pkg/ledger"
+
+type ledgerStore struct {
+	store *Store
+}
+
+func (l *ledgerStore) GetEntriesForUser(userID string) (map[string]ledger.Entry, error) {
+	l.store.mu.RLock()
+	defer l.store.mu.RUnlock()
+
+	entries := make(map[string]ledger.Entry)
+	for path, e := range l.store.entries {
+		if e.UserID == userID {
+			entries[path] = e
+		}
+	}
+	return entries, nil
+}
+
+func (l *ledgerStore) Skip(entry ledger.Entry) error {
+	entry.RowCount = 0
+	entry.Status = ledger.SKIPPED
+	entry.Error = ""
+	l.put(entry)
+	return nil
+}
+
+func (l *ledgerStore) Fail(entry ledger.Entry, cause error) error {
+	entry.RowCount = 0
+	entry.Status = ledger.FAILED
+	entry.Error = cause.Error()
+	l.put(entry)
+	return cause
+}
+
+func (l *ledgerStore) DeleteForUser(userID string) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	for path, e := range l.store.entries {
+		if e.UserID == userID {
+			delete(l.store.entries, path)
+		}
+	}
+	return nil
+}
+
+func (l *ledgerStore) put(entry ledger.Entry) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	l.store.entries[entry.Path] = entry
+}
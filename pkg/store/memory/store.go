@@ -0,0 +1,173 @@
+// Package memory implements the store interfaces in memory, for tests and quick
+// experiments without a database. A Store is safe for concurrent use, and its
+// queries give the same results as the MySQL store, including the case-insensitive
+// comparison of transportation modes.
+package memory
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/migrations"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+func New() *Store {
+	s := &Store{}
+	s.reset()
+	return s
+}
+
+var _ store.Store = (*Store)(nil)
+
+// Store keeps the activities and trackpoints of each user in insertion order.
+type Store struct {
+	mu          sync.RWMutex
+	users       map[string]user.User
+	activities  map[string][]activity.Activity
+	trackpoints map[string][]trackpoint.Trackpoint
+	entries     map[string]ledger.Entry
+	// lastActivityID and lastTrackpointID are the ids handed out last
+	lastActivityID   int
+	lastTrackpointID int
+}
+
+// reset removes everything. The caller has to hold the write lock or own s.
+func (s *Store) reset() {
+	s.users = make(map[string]user.User)
+	s.activities = make(map[string][]activity.Activity)
+	s.trackpoints = make(map[string][]trackpoint.Trackpoint)
+	s.entries = make(map[string]ledger.Entry)
+	s.lastActivityID = 0
+	s.lastTrackpointID = 0
+}
+
+func (s *Store) Users() store.UserStore {
+	return &userStore{s}
+}
+
+func (s *Store) Activities() store.ActivityStore {
+	return &activityStore{s}
+}
+
+func (s *Store) Trackpoints() store.TrackpointStore {
+	return &trackpointStore{s}
+}
+
+func (s *Store) Ledger() store.LedgerStore {
+	return &ledgerStore{s}
+}
+
+func (s *Store) Migrator() store.Migrator {
+	return &migrator{s}
+}
+
+// Prepare does nothing, the store has no schema.
+func (s *Store) Prepare() error {
+	return nil
+}
+
+func (s *Store) Begin() (store.Tx, error) {
+	return &tx{store: s}, nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// tx buffers the writes of a unit of work and applies them under the write lock
+// on Commit, so readers never see a part of them.
+type tx struct {
+	store       *Store
+	activities  []activity.Activity
+	trackpoints []trackpoint.Trackpoint
+	entries     []ledger.Entry
+	done        bool
+}
+
+func (t *tx) CreateActivities(activities []activity.Activity) error {
+	t.activities = append(t.activities, activities...)
+	return nil
+}
+
+func (t *tx) InsertTrackpoints(trackpoints []trackpoint.Trackpoint) error {
+	t.trackpoints = append(t.trackpoints, trackpoints...)
+	return nil
+}
+
+func (t *tx) Record(entries ...ledger.Entry) error {
+	for _, entry := range entries {
+		entry.Status = ledger.DONE
+		entry.Error = ""
+		t.entries = append(t.entries, entry)
+	}
+	return nil
+}
+
+func (t *tx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	s := t.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range t.activities {
+		s.lastActivityID++
+		a.ID = s.lastActivityID
+		s.activities[a.UserID] = append(s.activities[a.UserID], a)
+	}
+	for _, p := range t.trackpoints {
+		s.lastTrackpointID++
+		p.ID = s.lastTrackpointID
+		if p.ActivityID != nil {
+			// the loader points into its activity index, keep a copy of its own
+			activityID := *p.ActivityID
+			p.ActivityID = &activityID
+		}
+		s.trackpoints[p.UserID] = append(s.trackpoints[p.UserID], p)
+	}
+	for _, entry := range t.entries {
+		s.entries[entry.Path] = entry
+	}
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	return nil
+}
+
+// migrator stands in for the migrations of the other stores. There is no schema
+// to create, and resetting removes every stored row, like dropping the tables.
+type migrator struct {
+	store *Store
+}
+
+func (m *migrator) Up() ([]migrations.Migration, error) {
+	return nil, nil
+}
+
+func (m *migrator) Down() (*migrations.Migration, error) {
+	return nil, nil
+}
+
+func (m *migrator) Reset() ([]migrations.Migration, error) {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	m.store.reset()
+	return nil, nil
+}
+
+func (m *migrator) Status() ([]migrations.Status, error) {
+	return nil, nil
+}
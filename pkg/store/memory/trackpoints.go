@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"math"
+	"sort"
+
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+type trackpointStore struct {
+	store *Store
+}
+
+func (t *trackpointStore) GetCount() (int, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	count := 0
+	for _, points := range t.store.trackpoints {
+		count += len(points)
+	}
+	return count, nil
+}
+
+func (t *trackpointStore) GetCountForUser(userID string) (int, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+	return len(t.store.trackpoints[userID]), nil
+}
+
+func (t *trackpointStore) GetForUser(userID string, afterID, limit int) ([]trackpoint.Trackpoint, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	var points []trackpoint.Trackpoint
+	for _, p := range t.store.trackpoints[userID] {
+		if p.ID > afterID {
+			points = append(points, p)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].ID < points[j].ID
+	})
+	if len(points) > limit {
+		points = points[:limit]
+	}
+	return points, nil
+}
+
+func (t *trackpointStore) GetAround(userID string, lat, lon, span float64, limit int) ([]trackpoint.Trackpoint, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	var points []trackpoint.Trackpoint
+	for _, p := range t.store.trackpoints[userID] {
+		if math.Abs(p.Lat-lat) <= span && math.Abs(p.Lon-lon) <= span {
+			points = append(points, p)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].ID < points[j].ID
+	})
+	if len(points) > limit {
+		points = points[:limit]
+	}
+	return points, nil
+}
+
+func (t *trackpointStore) DeleteForUser(userID string) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+	delete(t.store.trackpoints, userID)
+	return nil
+}
+
+// sortByDate sorts trackpoints by date and then activity id, with trackpoints
+// without an activity first, which is the order of the act_date index.
+func sortByDate(points []trackpoint.Trackpoint) {
+	sort.SliceStable(points, func(i, j int) bool {
+		a, b := points[i], points[j]
+		if !a.DateTime.Equal(b.DateTime) {
+			return a.DateTime.Before(b.DateTime)
+		}
+		return activityID(a) < activityID(b)
+	})
+}
+
+// activityID returns the activity id of a trackpoint or 0 if it has none.
+func activityID(p trackpoint.Trackpoint) int {
+	if p.ActivityID == nil {
+		return 0
+	}
+	return *p.ActivityID
+}
@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+// aroundBox is the distance in degrees around a position that UsersAround searches.
+const aroundBox = 0.001
+
+// invalidGap is the time between two trackpoints of an activity that makes the
+// activity invalid.
+const invalidGap = 5 * time.Minute
+
+type userStore struct {
+	store *Store
+}
+
+func (u *userStore) CreateUser(id string, hasLabels bool) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+	u.store.users[id] = user.User{ID: id, HasLabels: hasLabels}
+	return nil
+}
+
+func (u *userStore) DeleteUser(id string) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+	delete(u.store.users, id)
+	return nil
+}
+
+func (u *userStore) GetUsers() ([]user.User, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+	return u.store.sortedUsers(), nil
+}
+
+func (u *userStore) GetCount() (int, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+	return len(u.store.users), nil
+}
+
+func (u *userStore) GetUsersThatHasUsedTransportationMode(transportationMode string) ([]string, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	users := []string{}
+	for userID, activities := range u.store.activities {
+		for _, a := range activities {
+			if strings.EqualFold(a.TransportationMode, transportationMode) {
+				users = append(users, userID)
+				break
+			}
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// GetUsersWithMostAltitude sums the altitude gained between consecutive
+// trackpoints of the walks of each user.
+func (u *userStore) GetUsersWithMostAltitude(numUsers int) ([]user.UserWithAltitude, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	users := u.store.sortedUsers()
+	usersWithAltitude := make([]user.UserWithAltitude, len(users))
+	for i, usr := range users {
+		walks := make(map[int]bool)
+		for _, a := range u.store.activities[usr.ID] {
+			if strings.EqualFold(a.TransportationMode, "walk") {
+				walks[a.ID] = true
+			}
+		}
+
+		var points []trackpoint.Trackpoint
+		for _, p := range u.store.trackpoints[usr.ID] {
+			if p.Altitude != nil && walks[activityID(p)] {
+				points = append(points, p)
+			}
+		}
+		sortByDate(points)
+
+		gained := 0.0
+		for j := 1; j < len(points); j++ {
+			prev, cur := points[j-1], points[j]
+			if activityID(prev) == activityID(cur) && *cur.Altitude > *prev.Altitude {
+				gained += *cur.Altitude - *prev.Altitude
+			}
+		}
+		usersWithAltitude[i] = user.UserWithAltitude{UserID: usr.ID, GainedAltitude: gained}
+	}
+
+	sort.SliceStable(usersWithAltitude, func(i, j int) bool {
+		return usersWithAltitude[i].GainedAltitude > usersWithAltitude[j].GainedAltitude
+	})
+	if len(usersWithAltitude) > numUsers {
+		usersWithAltitude = usersWithAltitude[:numUsers]
+	}
+	return usersWithAltitude, nil
+}
+
+// GetUsersWithInvalidActivites counts the activities per user in which two
+// consecutive trackpoints are at least invalidGap apart. Like the MySQL query it
+// walks all trackpoints in date order, so trackpoints of another activity in
+// between break up a gap.
+func (u *userStore) GetUsersWithInvalidActivites() ([]user.UserWithInvalidActivities, error) {
+	u.store.mu.RLock()
+	var points []trackpoint.Trackpoint
+	for _, userPoints := range u.store.trackpoints {
+		points = append(points, userPoints...)
+	}
+	u.store.mu.RUnlock()
+	sortByDate(points)
+
+	invalid := make(map[int]string)
+	for i := 1; i < len(points); i++ {
+		prev, cur := points[i-1], points[i]
+		if cur.ActivityID == nil || activityID(prev) != activityID(cur) {
+			continue
+		}
+		if cur.DateTime.Sub(prev.DateTime) >= invalidGap {
+			invalid[*cur.ActivityID] = cur.UserID
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, userID := range invalid {
+		counts[userID]++
+	}
+	users := make([]user.UserWithInvalidActivities, 0, len(counts))
+	for userID, count := range counts {
+		users = append(users, user.UserWithInvalidActivities{UserID: userID, InvalidActivities: count})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].UserID < users[j].UserID
+	})
+	return users, nil
+}
+
+func (u *userStore) UsersAround(lat, lon float64) ([]string, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	users := []string{}
+	for userID, points := range u.store.trackpoints {
+		for _, p := range points {
+			if math.Abs(p.Lat-lat) <= aroundBox && math.Abs(p.Lon-lon) <= aroundBox {
+				users = append(users, userID)
+				break
+			}
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// sortedUsers returns the users ordered by id. The caller has to hold the lock.
+func (s *Store) sortedUsers() []user.User {
+	users := make([]user.User, 0, len(s.users))
+	for _, usr := range s.users {
+		users = append(users, usr)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users
+}
@@ -0,0 +1,240 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type activityStore struct {
+	store *Store
+}
+
+// withoutTrackpoints leaves the embedded trackpoints out of the activities read.
+var withoutTrackpoints = bson.M{"trackpoints": 0}
+
+// year groups activities by the year they started in.
+var year = bson.M{"$year": "$start_date_time"}
+
+// hours is the duration of an activity truncated to whole hours, like
+// TIMESTAMPDIFF does in the MySQL query.
+var hours = bson.M{"$trunc": bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{"$end_date_time", "$start_date_time"}}, 3600 * 1000}}}
+
+func (a *activityStore) GetActivitiesForUser(userID string) ([]activity.Activity, error) {
+	opts := options.Find().SetProjection(withoutTrackpoints).SetSort(bson.M{"start_date_time": 1})
+	cursor, err := a.store.activities.Find(context.TODO(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []activityDoc
+	if err := cursor.All(context.TODO(), &docs); err != nil {
+		return nil, err
+	}
+
+	var activities []activity.Activity
+	for _, doc := range docs {
+		activities = append(activities, activity.Activity{
+			ID:                 doc.ID,
+			UserID:             doc.UserID,
+			TransportationMode: doc.TransportationMode,
+			StartDateTime:      doc.StartDateTime,
+			EndDateTime:        doc.EndDateTime,
+		})
+	}
+	return activities, nil
+}
+
+func (a *activityStore) DeleteForUser(userID string) error {
+	_, err := a.store.activities.DeleteMany(context.TODO(), bson.M{"user_id": userID})
+	return err
+}
+
+func (a *activityStore) GetCount() (int, error) {
+	count, err := a.store.activities.CountDocuments(context.TODO(), bson.M{})
+	return int(count), err
+}
+
+func (a *activityStore) AverageActivitesPerUser() (float64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$user_id", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "avg": bson.M{"$avg": "$count"}}}},
+	}
+	cursor, err := a.store.activities.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return 0, err
+	}
+
+	var results []struct {
+		Avg float64 `bson:"avg"`
+	}
+	if err := cursor.All(context.TODO(), &results); err != nil || len(results) == 0 {
+		return 0, err
+	}
+	return results[0].Avg, nil
+}
+
+func (a *activityStore) GetUsersActivityCount(limit int) ([]activity.UserCount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$user_id", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+	if limit != -1 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	cursor, err := a.store.activities.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var users []activity.UserCount
+	err = scanCounts(cursor, func(key string, count int) {
+		users = append(users, activity.UserCount{UserID: key, Activities: count})
+	})
+	return users, err
+}
+
+// GetTransportationCounts groups modes that only differ in case, since $group
+// compares with the collation of the aggregation.
+func (a *activityStore) GetTransportationCounts() ([]activity.ModeCount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$transportation_mode", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+	cursor, err := a.store.activities.Aggregate(context.TODO(), pipeline, options.Aggregate().SetCollation(caseInsensitive))
+	if err != nil {
+		return nil, err
+	}
+	var modes []activity.ModeCount
+	err = scanCounts(cursor, func(key string, count int) {
+		modes = append(modes, activity.ModeCount{TransportationMode: key, Activities: count})
+	})
+	return modes, err
+}
+
+func (a *activityStore) YearWithMostActivites() (int, int, error) {
+	years, err := a.byYear(bson.M{"$sum": 1}, bson.D{{Key: "value", Value: -1}, {Key: "_id", Value: 1}}, 1)
+	if err != nil || len(years) == 0 {
+		return 0, 0, err
+	}
+	return years[0].Year, years[0].Total, nil
+}
+
+func (a *activityStore) YearWithMostHours() (int, int, error) {
+	years, err := a.byYear(bson.M{"$sum": hours}, bson.D{{Key: "value", Value: -1}, {Key: "_id", Value: 1}}, 1)
+	if err != nil || len(years) == 0 {
+		return 0, 0, err
+	}
+	return years[0].Year, years[0].Total, nil
+}
+
+func (a *activityStore) GetActivityCountsByYear() ([]activity.YearTotal, error) {
+	return a.byYear(bson.M{"$sum": 1}, bson.D{{Key: "_id", Value: 1}}, 0)
+}
+
+func (a *activityStore) GetHoursByYear() ([]activity.YearTotal, error) {
+	return a.byYear(bson.M{"$sum": hours}, bson.D{{Key: "_id", Value: 1}}, 0)
+}
+
+// byYear groups the activities by the year they started in, accumulates each
+// group with accumulator and returns the totals of the years in the order of sort,
+// at most limit of them if limit is not 0.
+func (a *activityStore) byYear(accumulator bson.M, sort bson.D, limit int) ([]activity.YearTotal, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": year, "value": accumulator}}},
+		{{Key: "$sort", Value: sort}},
+	}
+	if limit != 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	cursor, err := a.store.activities.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		Year  int `bson:"_id"`
+		Value int `bson:"value"`
+	}
+	if err := cursor.All(context.TODO(), &results); err != nil {
+		return nil, err
+	}
+
+	years := make([]activity.YearTotal, len(results))
+	for i, r := range results {
+		years[i] = activity.YearTotal{Year: r.Year, Total: r.Value}
+	}
+	return years, nil
+}
+
+// GetDistanceByUser sums the distances between consecutive trackpoints of each
+// activity, read with the activity in one document.
+func (a *activityStore) GetDistanceByUser(userID, transportationMode string, year int) (float64, error) {
+	filter := bson.M{
+		"user_id":             userID,
+		"transportation_mode": transportationMode,
+		"start_date_time": bson.M{
+			"$gte": time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
+			"$lt":  time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	opts := options.Find().SetProjection(bson.M{"trackpoints.lat": 1, "trackpoints.lon": 1}).SetCollation(caseInsensitive)
+	cursor, err := a.store.activities.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.TODO())
+
+	distance := 0.0
+	for cursor.Next(context.TODO()) {
+		var walk activityDoc
+		if err := cursor.Decode(&walk); err != nil {
+			return 0, err
+		}
+		points := walk.Trackpoints
+		for i := 1; i < len(points); i++ {
+			distance += activity.Distance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+		}
+	}
+	return distance, cursor.Err()
+}
+
+// GetTopTransportationByUsers returns the most used mode of every user with
+// activities, ordered by user id. Ties go to the mode that sorts first.
+func (a *activityStore) GetTopTransportationByUsers() ([]activity.TopMode, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "mode": "$transportation_mode"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.user_id", Value: 1}, {Key: "count", Value: -1}, {Key: "_id.mode", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$_id.user_id",
+			"mode":  bson.M{"$first": "$_id.mode"},
+			"count": bson.M{"$first": "$count"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := a.store.activities.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		UserID string `bson:"_id"`
+		Mode   string `bson:"mode"`
+		Count  int    `bson:"count"`
+	}
+	if err := cursor.All(context.TODO(), &results); err != nil {
+		return nil, err
+	}
+
+	var top []activity.TopMode
+	for _, r := range results {
+		top = append(top, activity.TopMode{UserID: r.UserID, TransportationMode: r.Mode, Activities: r.Count})
+	}
+	return top, nil
+}
@@ -0,0 +1,103 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ledgerStore struct {
+	store *Store
+}
+
+// entryDoc is a document of the ingestion_ledger collection, keyed by path.
+type entryDoc struct {
+	Path       string    `bson:"_id"`
+	UserID     string    `bson:"user_id"`
+	Kind       string    `bson:"kind"`
+	Size       int64     `bson:"size"`
+	ModTime    int64     `bson:"mod_time"`
+	Checksum   string    `bson:"checksum"`
+	RowCount   int       `bson:"row_count"`
+	Status     string    `bson:"status"`
+	Error      string    `bson:"error,omitempty"`
+	SizePolicy string    `bson:"size_policy,omitempty"`
+	MaxPoints  int       `bson:"max_points,omitempty"`
+	UpdatedAt  time.Time `bson:"updated_at"`
+}
+
+func (l *ledgerStore) GetEntriesForUser(userID string) (map[string]ledger.Entry, error) {
+	cursor, err := l.store.ledger.Find(context.TODO(), bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []entryDoc
+	if err := cursor.All(context.TODO(), &docs); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]ledger.Entry)
+	for _, doc := range docs {
+		entries[doc.Path] = ledger.Entry{
+			Path:       doc.Path,
+			UserID:     doc.UserID,
+			Kind:       ledger.Kind(doc.Kind),
+			Size:       doc.Size,
+			ModTime:    time.Unix(0, doc.ModTime),
+			Checksum:   doc.Checksum,
+			RowCount:   doc.RowCount,
+			Status:     ledger.Status(doc.Status),
+			Error:      doc.Error,
+			SizePolicy: doc.SizePolicy,
+			MaxPoints:  doc.MaxPoints,
+		}
+	}
+	return entries, nil
+}
+
+func (l *ledgerStore) Skip(entry ledger.Entry) error {
+	entry.RowCount = 0
+	entry.Status = ledger.SKIPPED
+	entry.Error = ""
+	return putEntry(context.TODO(), l.store.ledger, entry)
+}
+
+func (l *ledgerStore) Fail(entry ledger.Entry, cause error) error {
+	entry.RowCount = 0
+	entry.Status = ledger.FAILED
+	entry.Error = cause.Error()
+	if err := putEntry(context.TODO(), l.store.ledger, entry); err != nil {
+		return err
+	}
+	return cause
+}
+
+func (l *ledgerStore) DeleteForUser(userID string) error {
+	_, err := l.store.ledger.DeleteMany(context.TODO(), bson.M{"user_id": userID})
+	return err
+}
+
+// putEntry inserts or replaces the ledger entry of a file.
+func putEntry(ctx context.Context, ledgerColl *mongo.Collection, e ledger.Entry) error {
+	doc := entryDoc{
+		Path:       e.Path,
+		UserID:     e.UserID,
+		Kind:       string(e.Kind),
+		Size:       e.Size,
+		ModTime:    e.ModTime.UnixNano(),
+		Checksum:   e.Checksum,
+		RowCount:   e.RowCount,
+		Status:     string(e.Status),
+		Error:      e.Error,
+		SizePolicy: e.SizePolicy,
+		MaxPoints:  e.MaxPoints,
+		UpdatedAt:  time.Now(),
+	}
+	_, err := ledgerColl.ReplaceOne(ctx, bson.M{"_id": e.Path}, doc, options.Replace().SetUpsert(true))
+	return err
+}
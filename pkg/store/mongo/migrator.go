@@ -0,0 +1,146 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/migrations"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Error codes of commands that create an existing or drop a missing collection.
+const (
+	codeNamespaceNotFound = 26
+	codeNamespaceExists   = 48
+)
+
+// migrator applies the migrations of migrations.Mongo, whose statements are
+// database commands in extended JSON, and records the applied versions in the
+// schema_migrations collection. Creating an existing or dropping a missing
+// collection is not an error, like CREATE TABLE IF NOT EXISTS.
+type migrator struct {
+	db         *mongo.Database
+	migrations []migrations.Migration
+}
+
+type appliedDoc struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// Up applies every pending migration in order and returns the applied ones.
+func (m *migrator) Up() ([]migrations.Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []migrations.Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.exec(migration, migration.Up); err != nil {
+			return done, err
+		}
+		doc := appliedDoc{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+		if _, err := m.db.Collection("schema_migrations").InsertOne(context.TODO(), doc); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the latest applied migration. It returns nil if no migration
+// is applied.
+func (m *migrator) Down() (*migrations.Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.exec(migration, migration.Down); err != nil {
+			return nil, err
+		}
+		if _, err := m.db.Collection("schema_migrations").DeleteOne(context.TODO(), bson.M{"_id": migration.Version}); err != nil {
+			return nil, err
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+// Reset rolls back every applied migration and returns the rolled back ones.
+func (m *migrator) Reset() ([]migrations.Migration, error) {
+	var done []migrations.Migration
+	for {
+		migration, err := m.Down()
+		if err != nil {
+			return done, err
+		}
+		if migration == nil {
+			return done, nil
+		}
+		done = append(done, *migration)
+	}
+}
+
+func (m *migrator) Status() ([]migrations.Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]migrations.Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = migrations.Status{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+	}
+	return statuses, nil
+}
+
+func (m *migrator) applied() (map[int]time.Time, error) {
+	cursor, err := m.db.Collection("schema_migrations").Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []appliedDoc
+	if err := cursor.All(context.TODO(), &docs); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time)
+	for _, doc := range docs {
+		applied[doc.Version] = doc.AppliedAt
+	}
+	return applied, nil
+}
+
+func (m *migrator) exec(migration migrations.Migration, commands []string) error {
+	for _, command := range commands {
+		var cmd bson.D
+		if err := bson.UnmarshalExtJSON([]byte(command), false, &cmd); err != nil {
+			return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		err := m.db.RunCommand(context.TODO(), cmd).Err()
+		if e, ok := err.(mongo.CommandError); ok && (e.HasErrorCode(codeNamespaceExists) || e.HasErrorCode(codeNamespaceNotFound)) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
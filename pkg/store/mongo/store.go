@@ -0,0 +1,384 @@
+// Package mongo implements the store interfaces on a MongoDB document store.
+// Every activity is a document that embeds its trackpoints, and the trackpoints
+// of a trajectory that fall into no activity are stored as trajectory documents,
+// so the relational and the document layout of the dataset can be compared.
+package mongo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/migrations"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultDatabase is the database used when the URI does not name one.
+const defaultDatabase = "strava"
+
+// trajectoryGap is the time between two trackpoints without an activity that
+// starts a new trajectory document.
+const trajectoryGap = 5 * time.Minute
+
+var errMissingActivity = errors.New("mongo: trackpoints of an activity that does not exist")
+
+// caseInsensitive compares transportation modes like the MySQL collation does.
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// Open connects to the MongoDB deployment at uri and uses the database named in
+// its path, strava if it names none. The writes of a transaction are applied in
+// a MongoDB transaction when the deployment is a replica set or a sharded
+// cluster. A standalone server has no transactions, so a write that fails there
+// can leave part of a transaction behind.
+func Open(uri string) (*Store, error) {
+	database := defaultDatabase
+	if u, err := url.Parse(uri); err == nil && strings.Trim(u.Path, "/") != "" {
+		database = strings.Trim(u.Path, "/")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello)
+	if err != nil {
+		client.Disconnect(context.TODO())
+		return nil, err
+	}
+
+	db := client.Database(database)
+	s := &Store{
+		client:       client,
+		db:           db,
+		transactions: hello.SetName != "" || hello.Msg == "isdbgrid",
+		users:        db.Collection("users"),
+		activities:   db.Collection("activities"),
+		trajectories: db.Collection("trajectories"),
+		counters:     db.Collection("counters"),
+		ledger:       db.Collection("ingestion_ledger"),
+	}
+	s.migrator = &migrator{db: db, migrations: migrations.Mongo}
+	return s, nil
+}
+
+var _ store.Store = (*Store)(nil)
+
+type Store struct {
+	client *mongo.Client
+	db     *mongo.Database
+	// transactions is set if the deployment supports multi-document transactions
+	transactions bool
+	users        *mongo.Collection
+	activities   *mongo.Collection
+	trajectories *mongo.Collection
+	counters     *mongo.Collection
+	ledger       *mongo.Collection
+	migrator     *migrator
+}
+
+func (s *Store) Users() store.UserStore {
+	return &userStore{s}
+}
+
+func (s *Store) Activities() store.ActivityStore {
+	return &activityStore{s}
+}
+
+func (s *Store) Trackpoints() store.TrackpointStore {
+	return &trackpointStore{s}
+}
+
+func (s *Store) Ledger() store.LedgerStore {
+	return &ledgerStore{s}
+}
+
+func (s *Store) Migrator() store.Migrator {
+	return s.migrator
+}
+
+// Prepare does nothing, the store has no statements to prepare.
+func (s *Store) Prepare() error {
+	return nil
+}
+
+func (s *Store) Begin() (store.Tx, error) {
+	return &tx{store: s}, nil
+}
+
+func (s *Store) Close() error {
+	return s.client.Disconnect(context.TODO())
+}
+
+// userDoc is a document of the users collection.
+type userDoc struct {
+	ID        string `bson:"_id"`
+	HasLabels bool   `bson:"has_labels"`
+}
+
+// activityDoc is a document of the activities collection. Its trackpoints are
+// kept sorted by date.
+type activityDoc struct {
+	ID                 int             `bson:"_id"`
+	UserID             string          `bson:"user_id"`
+	TransportationMode string          `bson:"transportation_mode"`
+	StartDateTime      time.Time       `bson:"start_date_time"`
+	EndDateTime        time.Time       `bson:"end_date_time"`
+	Trackpoints        []trackpointDoc `bson:"trackpoints"`
+}
+
+// trajectoryDoc is a document of the trajectories collection, a run of
+// trackpoints of a user without an activity.
+type trajectoryDoc struct {
+	UserID        string          `bson:"user_id"`
+	StartDateTime time.Time       `bson:"start_date_time"`
+	EndDateTime   time.Time       `bson:"end_date_time"`
+	Trackpoints   []trackpointDoc `bson:"trackpoints"`
+}
+
+// trackpointDoc is a trackpoint embedded in an activity or trajectory.
+type trackpointDoc struct {
+	ID          int       `bson:"id"`
+	Lat         float64   `bson:"lat"`
+	Lon         float64   `bson:"lon"`
+	Altitude    *float64  `bson:"altitude"`
+	AltitudeRaw float64   `bson:"altitude_raw"`
+	DateDays    float64   `bson:"date_days"`
+	DateTime    time.Time `bson:"date_time"`
+}
+
+func newTrackpointDoc(p trackpoint.Trackpoint) trackpointDoc {
+	return trackpointDoc{
+		ID:          p.ID,
+		Lat:         p.Lat,
+		Lon:         p.Lon,
+		Altitude:    p.Altitude,
+		AltitudeRaw: p.AltitudeRaw,
+		DateDays:    p.DateDays,
+		DateTime:    p.DateTime,
+	}
+}
+
+func (d trackpointDoc) trackpoint(userID string, activityID *int) trackpoint.Trackpoint {
+	return trackpoint.Trackpoint{
+		ID:          d.ID,
+		UserID:      userID,
+		ActivityID:  activityID,
+		Lat:         d.Lat,
+		Lon:         d.Lon,
+		Altitude:    d.Altitude,
+		AltitudeRaw: d.AltitudeRaw,
+		DateDays:    d.DateDays,
+		DateTime:    d.DateTime,
+	}
+}
+
+// reserve hands out n ids of the counter name and returns the first of them.
+// Ids are reserved outside of transactions, so the writers of the loader do not
+// conflict on the counter, and ids of rolled back writes are not reused.
+func (s *Store) reserve(name string, n int) (int, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.counters.FindOneAndUpdate(context.TODO(), bson.M{"_id": name}, bson.M{"$inc": bson.M{"seq": n}}, opts).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq - n + 1, nil
+}
+
+// tx buffers the writes of a unit of work and applies them on Commit, in a
+// MongoDB transaction if the deployment supports them.
+type tx struct {
+	store       *Store
+	activities  []activity.Activity
+	trackpoints []trackpoint.Trackpoint
+	entries     []ledger.Entry
+	done        bool
+}
+
+func (t *tx) CreateActivities(activities []activity.Activity) error {
+	t.activities = append(t.activities, activities...)
+	return nil
+}
+
+func (t *tx) InsertTrackpoints(trackpoints []trackpoint.Trackpoint) error {
+	t.trackpoints = append(t.trackpoints, trackpoints...)
+	return nil
+}
+
+func (t *tx) Record(entries ...ledger.Entry) error {
+	for _, entry := range entries {
+		entry.Status = ledger.DONE
+		entry.Error = ""
+		t.entries = append(t.entries, entry)
+	}
+	return nil
+}
+
+func (t *tx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	activities, err := t.activityDocs()
+	if err != nil {
+		return err
+	}
+	pushes, trajectories, err := t.trackpointDocs()
+	if err != nil {
+		return err
+	}
+
+	return t.store.inTransaction(func(ctx context.Context) error {
+		if len(activities) > 0 {
+			if _, err := t.store.activities.InsertMany(ctx, activities); err != nil {
+				return err
+			}
+		}
+		if len(pushes) > 0 {
+			result, err := t.store.activities.BulkWrite(ctx, pushes)
+			if err != nil {
+				return err
+			}
+			if int(result.MatchedCount) != len(pushes) {
+				return errMissingActivity
+			}
+		}
+		if len(trajectories) > 0 {
+			if _, err := t.store.trajectories.InsertMany(ctx, trajectories); err != nil {
+				return err
+			}
+		}
+		for _, entry := range t.entries {
+			if err := putEntry(ctx, t.store.ledger, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (t *tx) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	return nil
+}
+
+// activityDocs numbers the buffered activities and returns their documents.
+func (t *tx) activityDocs() ([]interface{}, error) {
+	if len(t.activities) == 0 {
+		return nil, nil
+	}
+	first, err := t.store.reserve("activities", len(t.activities))
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]interface{}, len(t.activities))
+	for i, a := range t.activities {
+		docs[i] = activityDoc{
+			ID:                 first + i,
+			UserID:             a.UserID,
+			TransportationMode: a.TransportationMode,
+			StartDateTime:      a.StartDateTime,
+			EndDateTime:        a.EndDateTime,
+			Trackpoints:        []trackpointDoc{},
+		}
+	}
+	return docs, nil
+}
+
+// trackpointDocs numbers the buffered trackpoints. It returns an update per
+// activity that pushes its trackpoints into the activity document, and a
+// trajectory document for every run of consecutive trackpoints of a user without
+// an activity. A run ends at a trackpoint of an activity or a gap of
+// trajectoryGap, which also separates the trajectory files of a batch.
+func (t *tx) trackpointDocs() ([]mongo.WriteModel, []interface{}, error) {
+	if len(t.trackpoints) == 0 {
+		return nil, nil, nil
+	}
+	first, err := t.store.reserve("trackpoints", len(t.trackpoints))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var order []int
+	byActivity := make(map[int][]trackpointDoc)
+	var trajectories []interface{}
+	var run *trajectoryDoc
+	for i, p := range t.trackpoints {
+		p.ID = first + i
+		doc := newTrackpointDoc(p)
+
+		if p.ActivityID != nil {
+			if _, ok := byActivity[*p.ActivityID]; !ok {
+				order = append(order, *p.ActivityID)
+			}
+			byActivity[*p.ActivityID] = append(byActivity[*p.ActivityID], doc)
+			run = nil
+			continue
+		}
+
+		if run == nil || run.UserID != p.UserID || !p.DateTime.After(run.EndDateTime) || p.DateTime.Sub(run.EndDateTime) >= trajectoryGap {
+			if run != nil {
+				trajectories = append(trajectories, *run)
+			}
+			run = &trajectoryDoc{UserID: p.UserID, StartDateTime: p.DateTime}
+		}
+		run.EndDateTime = p.DateTime
+		run.Trackpoints = append(run.Trackpoints, doc)
+	}
+	if run != nil {
+		trajectories = append(trajectories, *run)
+	}
+
+	pushes := make([]mongo.WriteModel, len(order))
+	for i, activityID := range order {
+		push := bson.M{"$push": bson.M{"trackpoints": bson.M{
+			"$each": byActivity[activityID],
+			"$sort": bson.M{"date_time": 1},
+		}}}
+		pushes[i] = mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": activityID}).SetUpdate(push)
+	}
+	return pushes, trajectories, nil
+}
+
+// inTransaction runs fn in a MongoDB transaction that is retried on transient
+// errors, or directly if the deployment has no transactions.
+func (s *Store) inTransaction(fn func(ctx context.Context) error) error {
+	if !s.transactions {
+		return fn(context.TODO())
+	}
+
+	session, err := s.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.TODO())
+
+	_, err = session.WithTransaction(context.TODO(), func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
@@ -0,0 +1,119 @@
+package mongo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type trackpointStore struct {
+	store *Store
+}
+
+// GetCount adds up the trackpoints embedded in the activities and trajectories.
+func (t *trackpointStore) GetCount() (int, error) {
+	return t.count(bson.M{})
+}
+
+func (t *trackpointStore) GetCountForUser(userID string) (int, error) {
+	return t.count(bson.M{"user_id": userID})
+}
+
+func (t *trackpointStore) count(filter bson.M) (int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "count": bson.M{"$sum": bson.M{"$size": "$trackpoints"}}}}},
+	}
+
+	total := 0
+	for _, coll := range []*mongo.Collection{t.store.activities, t.store.trajectories} {
+		cursor, err := coll.Aggregate(context.TODO(), pipeline)
+		if err != nil {
+			return 0, err
+		}
+		err = scanCounts(cursor, func(_ string, count int) {
+			total += count
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// GetForUser unwinds the trackpoints of the activities and the trajectories of
+// the user and merges them by id.
+func (t *trackpointStore) GetForUser(userID string, afterID, limit int) ([]trackpoint.Trackpoint, error) {
+	return t.find(userID, bson.M{"id": bson.M{"$gt": afterID}}, limit)
+}
+
+func (t *trackpointStore) GetAround(userID string, lat, lon, span float64, limit int) ([]trackpoint.Trackpoint, error) {
+	return t.find(userID, bson.M{
+		"lat": bson.M{"$gte": lat - span, "$lte": lat + span},
+		"lon": bson.M{"$gte": lon - span, "$lte": lon + span},
+	}, limit)
+}
+
+// find returns at most limit trackpoints of the activities and trajectories of
+// a user that match filter, by id. The keys of filter are fields of a
+// trackpoint.
+func (t *trackpointStore) find(userID string, filter bson.M, limit int) ([]trackpoint.Trackpoint, error) {
+	unwound := bson.M{}
+	for key, value := range filter {
+		unwound["trackpoints."+key] = value
+	}
+
+	var points []trackpoint.Trackpoint
+	for _, source := range []struct {
+		coll       *mongo.Collection
+		activityID interface{}
+	}{
+		{t.store.activities, "$_id"},
+		{t.store.trajectories, bson.M{"$literal": nil}},
+	} {
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"user_id": userID, "trackpoints": bson.M{"$elemMatch": filter}}}},
+			{{Key: "$unwind", Value: "$trackpoints"}},
+			{{Key: "$match", Value: unwound}},
+			{{Key: "$sort", Value: bson.M{"trackpoints.id": 1}}},
+			{{Key: "$limit", Value: limit}},
+			{{Key: "$project", Value: bson.M{"_id": 0, "activity_id": source.activityID, "trackpoints": 1}}},
+		}
+		cursor, err := source.coll.Aggregate(context.TODO(), pipeline)
+		if err != nil {
+			return nil, err
+		}
+
+		var results []struct {
+			ActivityID *int          `bson:"activity_id"`
+			Trackpoint trackpointDoc `bson:"trackpoints"`
+		}
+		if err := cursor.All(context.TODO(), &results); err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			points = append(points, r.Trackpoint.trackpoint(userID, r.ActivityID))
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].ID < points[j].ID
+	})
+	if len(points) > limit {
+		points = points[:limit]
+	}
+	return points, nil
+}
+
+// DeleteForUser empties the activities of the user and deletes its trajectories.
+func (t *trackpointStore) DeleteForUser(userID string) error {
+	_, err := t.store.activities.UpdateMany(context.TODO(), bson.M{"user_id": userID}, bson.M{"$set": bson.M{"trackpoints": bson.A{}}})
+	if err != nil {
+		return err
+	}
+	_, err = t.store.trajectories.DeleteMany(context.TODO(), bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,256 @@
+package mongo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/user"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// aroundBox is the distance in degrees around a position that UsersAround searches.
+const aroundBox = 0.001
+
+// invalidGap is the time between two trackpoints of an activity that makes the
+// activity invalid.
+const invalidGap = 5 * time.Minute
+
+type userStore struct {
+	store *Store
+}
+
+func (u *userStore) CreateUser(id string, hasLabels bool) error {
+	_, err := u.store.users.ReplaceOne(context.TODO(), bson.M{"_id": id}, userDoc{ID: id, HasLabels: hasLabels}, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (u *userStore) DeleteUser(id string) error {
+	_, err := u.store.users.DeleteOne(context.TODO(), bson.M{"_id": id})
+	return err
+}
+
+func (u *userStore) GetUsers() ([]user.User, error) {
+	cursor, err := u.store.users.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []userDoc
+	if err := cursor.All(context.TODO(), &docs); err != nil {
+		return nil, err
+	}
+
+	users := make([]user.User, len(docs))
+	for i, doc := range docs {
+		users[i] = user.User{ID: doc.ID, HasLabels: doc.HasLabels}
+	}
+	return users, nil
+}
+
+func (u *userStore) GetCount() (int, error) {
+	count, err := u.store.users.CountDocuments(context.TODO(), bson.M{})
+	return int(count), err
+}
+
+func (u *userStore) GetUsersThatHasUsedTransportationMode(transportationMode string) ([]string, error) {
+	opts := options.Distinct().SetCollation(caseInsensitive)
+	values, err := u.store.activities.Distinct(context.TODO(), "user_id", bson.M{"transportation_mode": transportationMode}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return sortedStrings(values), nil
+}
+
+// GetUsersWithMostAltitude sums the altitude gained between consecutive
+// trackpoints with an altitude of each walk in the database, with $reduce over
+// the trackpoints embedded in the walk, which are sorted by date.
+func (u *userStore) GetUsersWithMostAltitude(numUsers int) ([]user.UserWithAltitude, error) {
+	users, err := u.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"transportation_mode": "walk"}}},
+		{{Key: "$project", Value: bson.M{
+			"user_id": 1,
+			"gained": bson.M{"$reduce": bson.M{
+				"input": bson.M{"$filter": bson.M{
+					"input": "$trackpoints.altitude",
+					"cond":  bson.M{"$ne": bson.A{"$$this", nil}},
+				}},
+				"initialValue": bson.M{"prev": nil, "gained": 0.0},
+				"in": bson.M{
+					"prev": "$$this",
+					"gained": bson.M{"$add": bson.A{"$$value.gained", bson.M{"$cond": bson.A{
+						bson.M{"$and": bson.A{bson.M{"$ne": bson.A{"$$value.prev", nil}}, bson.M{"$gt": bson.A{"$$this", "$$value.prev"}}}},
+						bson.M{"$subtract": bson.A{"$$this", "$$value.prev"}},
+						0.0,
+					}}}},
+				},
+			}},
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": "$user_id", "gained": bson.M{"$sum": "$gained.gained"}}}},
+	}
+	cursor, err := u.store.activities.Aggregate(context.TODO(), pipeline, options.Aggregate().SetCollation(caseInsensitive))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		UserID string  `bson:"_id"`
+		Gained float64 `bson:"gained"`
+	}
+	if err := cursor.All(context.TODO(), &results); err != nil {
+		return nil, err
+	}
+	gained := make(map[string]float64)
+	for _, r := range results {
+		gained[r.UserID] = r.Gained
+	}
+
+	usersWithAltitude := make([]user.UserWithAltitude, len(users))
+	for i, usr := range users {
+		usersWithAltitude[i] = user.UserWithAltitude{UserID: usr.ID, GainedAltitude: gained[usr.ID]}
+	}
+	sort.SliceStable(usersWithAltitude, func(i, j int) bool {
+		return usersWithAltitude[i].GainedAltitude > usersWithAltitude[j].GainedAltitude
+	})
+	if len(usersWithAltitude) > numUsers {
+		usersWithAltitude = usersWithAltitude[:numUsers]
+	}
+	return usersWithAltitude, nil
+}
+
+// GetUsersWithInvalidActivites counts the activities per user in which two
+// consecutive trackpoints are at least 5 minutes apart. Like the MySQL query it
+// walks the trackpoints of all activities and trajectories in date order, so a
+// trackpoint of another activity or of no activity in between breaks up a gap.
+// The server sorts the trackpoints, on disk if they do not fit in memory, and
+// gapWalk only keeps the one before.
+func (u *userStore) GetUsersWithInvalidActivites() ([]user.UserWithInvalidActivities, error) {
+	unwind := func(activityID interface{}) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$unwind", Value: "$trackpoints"}},
+			{{Key: "$project", Value: bson.M{"_id": 0, "user_id": 1, "activity_id": activityID, "date_time": "$trackpoints.date_time"}}},
+		}
+	}
+	pipeline := append(unwind("$_id"),
+		bson.D{{Key: "$unionWith", Value: bson.M{"coll": u.store.trajectories.Name(), "pipeline": unwind(bson.M{"$literal": nil})}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "date_time", Value: 1}, {Key: "activity_id", Value: 1}}}},
+	)
+	cursor, err := u.store.activities.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	walk := newGapWalk()
+	for cursor.Next(context.TODO()) {
+		var p struct {
+			UserID     string    `bson:"user_id"`
+			ActivityID *int      `bson:"activity_id"`
+			DateTime   time.Time `bson:"date_time"`
+		}
+		if err := cursor.Decode(&p); err != nil {
+			return nil, err
+		}
+		walk.add(p.UserID, p.ActivityID, p.DateTime)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return walk.users(), nil
+}
+
+// gapWalk finds the invalid activities in trackpoints that are added in date
+// order, and then by activity id.
+type gapWalk struct {
+	prevActivity *int
+	prevDate     time.Time
+	// invalid are the users of the invalid activities by activity id
+	invalid map[int]string
+}
+
+func newGapWalk() *gapWalk {
+	return &gapWalk{invalid: make(map[int]string)}
+}
+
+// add compares a trackpoint with the one added before it. Only two trackpoints
+// of the same activity in a row can make it invalid.
+func (w *gapWalk) add(userID string, activityID *int, date time.Time) {
+	if activityID != nil && w.prevActivity != nil && *activityID == *w.prevActivity && date.Sub(w.prevDate) >= invalidGap {
+		w.invalid[*activityID] = userID
+	}
+	w.prevActivity, w.prevDate = activityID, date
+}
+
+// users returns the number of invalid activities of every user that has any,
+// by user id.
+func (w *gapWalk) users() []user.UserWithInvalidActivities {
+	counts := make(map[string]int)
+	for _, userID := range w.invalid {
+		counts[userID]++
+	}
+	users := make([]user.UserWithInvalidActivities, 0, len(counts))
+	for userID, count := range counts {
+		users = append(users, user.UserWithInvalidActivities{UserID: userID, InvalidActivities: count})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].UserID < users[j].UserID
+	})
+	return users
+}
+
+// UsersAround searches the trackpoints of both the activities and the
+// trajectories.
+func (u *userStore) UsersAround(lat, lon float64) ([]string, error) {
+	filter := bson.M{"trackpoints": bson.M{"$elemMatch": bson.M{
+		"lat": bson.M{"$gte": lat - aroundBox, "$lte": lat + aroundBox},
+		"lon": bson.M{"$gte": lon - aroundBox, "$lte": lon + aroundBox},
+	}}}
+
+	var values []interface{}
+	for _, coll := range []*mongo.Collection{u.store.activities, u.store.trajectories} {
+		found, err := coll.Distinct(context.TODO(), "user_id", filter)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, found...)
+	}
+	return sortedStrings(values), nil
+}
+
+// sortedStrings returns the distinct strings of values in order.
+func sortedStrings(values []interface{}) []string {
+	seen := make(map[string]bool)
+	strs := []string{}
+	for _, v := range values {
+		if s, ok := v.(string); ok && !seen[s] {
+			seen[s] = true
+			strs = append(strs, s)
+		}
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+// scanCounts decodes the documents of an aggregation that groups by a string
+// _id and counts into count.
+func scanCounts(cursor *mongo.Cursor, add func(key string, count int)) error {
+	var results []struct {
+		Key   string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(context.TODO(), &results); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		add(r.Key, r.Count)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+func TestGapWalk(t *testing.T) {
+	start := time.Date(2008, 10, 28, 12, 0, 0, 0, time.UTC)
+	id := func(i int) *int { return &i }
+	type point struct {
+		userID     string
+		activityID *int
+		minute     float64
+	}
+
+	tests := []struct {
+		name   string
+		points []point
+		want   []user.UserWithInvalidActivities
+	}{
+		{
+			name:   "gap of 5 minutes",
+			points: []point{{"010", id(1), 0}, {"010", id(1), 5}},
+			want:   []user.UserWithInvalidActivities{{UserID: "010", InvalidActivities: 1}},
+		},
+		{
+			name:   "gap just under 5 minutes",
+			points: []point{{"010", id(1), 0}, {"010", id(1), 4.99}},
+			want:   []user.UserWithInvalidActivities{},
+		},
+		{
+			name:   "another activity in between",
+			points: []point{{"010", id(1), 0}, {"011", id(2), 3}, {"010", id(1), 6}},
+			want:   []user.UserWithInvalidActivities{},
+		},
+		{
+			name:   "a trajectory in between",
+			points: []point{{"010", id(1), 0}, {"010", nil, 3}, {"010", id(1), 6}},
+			want:   []user.UserWithInvalidActivities{},
+		},
+		{
+			name:   "gaps between trajectory points",
+			points: []point{{"010", nil, 0}, {"010", nil, 10}},
+			want:   []user.UserWithInvalidActivities{},
+		},
+		{
+			name: "activities counted once per user",
+			points: []point{
+				{"010", id(1), 0}, {"010", id(1), 5}, {"010", id(1), 10},
+				{"011", id(2), 20}, {"011", id(2), 30},
+				{"010", id(3), 40}, {"010", id(3), 50},
+			},
+			want: []user.UserWithInvalidActivities{
+				{UserID: "010", InvalidActivities: 2},
+				{UserID: "011", InvalidActivities: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			walk := newGapWalk()
+			for _, p := range test.points {
+				walk.add(p.userID, p.activityID, start.Add(time.Duration(p.minute*float64(time.Minute))))
+			}
+			if got := walk.users(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/replica"
+)
+
+type activityStore struct {
+	db                           *sql.DB
+	replicas                     *replica.Set
+	insertActivityStmt           *sql.Stmt
+	queryActivityIDWithTimeStamp *sql.Stmt
+	queryActivitiesForUser       *sql.Stmt
+}
+
+// SetReplicas sends the read-only queries of the tasks to the replicas of set.
+func (a *activityStore) SetReplicas(set *replica.Set) {
+	a.replicas = set
+}
+
+// reader returns the database a read-only query of a task runs on.
+func (a *activityStore) reader() *sql.DB {
+	if a.replicas == nil {
+		return a.db
+	}
+	return a.replicas.Reader()
+}
+
+func (a *activityStore) LoadStatements() error {
+	insertActivityStmt, err := a.db.PrepareContext(context.TODO(), "INSERT INTO Activity(user_id, transportation_mode, start_date_time, end_date_time) VALUES( ?, ?, ?, ? )")
+	if err != nil {
+		return err
+	}
+	queryActivityIDWithTimeStamp, err := a.db.PrepareContext(context.TODO(), "SELECT id FROM Activity WHERE ? BETWEEN start_date_time AND end_date_time")
+	if err != nil {
+		return err
+	}
+	queryActivitiesForUser, err := a.db.PrepareContext(context.TODO(), "SELECT * FROM Activity WHERE user_id = ? ORDER BY start_date_time ASC")
+	if err != nil {
+		return err
+	}
+	a.insertActivityStmt = insertActivityStmt
+	a.queryActivityIDWithTimeStamp = queryActivityIDWithTimeStamp
+	a.queryActivitiesForUser = queryActivitiesForUser
+	return nil
+}
+
+func (a *activityStore) CreateActivity(userID, transportationMode string, startDateTime, endDateTime time.Time) error {
+	_, err := a.insertActivityStmt.ExecContext(context.TODO(), userID, transportationMode, startDateTime, endDateTime)
+	return err
+}
+
+func (a *activityStore) BulkCreateActivity(activities []activity.Activity, numActivities int) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = a.BulkCreateActivityTx(tx, activities, numActivities)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// BulkCreateActivityTx inserts the first numActivities activities as part of tx.
+func (a *activityStore) BulkCreateActivityTx(tx *sql.Tx, activities []activity.Activity, numActivities int) error {
+	valueArgs := make([]interface{}, numActivities*4, numActivities*4)
+
+	var b strings.Builder
+	size := unsafe.Sizeof("(?, ?, ?, ?),")
+	b.Grow(int(size) * numActivities)
+
+	fmt.Fprintf(&b, "INSERT INTO Activity(user_id, transportation_mode, start_date_time, end_date_time) VALUES ")
+	for i := 0; i < numActivities; i++ {
+		if i == numActivities-1 {
+			fmt.Fprintf(&b, "(?, ?, ?, ?)")
+		} else {
+			fmt.Fprintf(&b, "(?, ?, ?, ?),")
+
+			// alternative:
+			// stmt := b.String()
+			// stmt = stmt[:len(stmt)-1]
+		}
+
+		a := activities[i]
+		index := i * 4
+		valueArgs[index] = a.UserID
+		valueArgs[index+1] = a.TransportationMode
+		valueArgs[index+2] = a.StartDateTime
+		valueArgs[index+3] = a.EndDateTime
+	}
+
+	_, err := tx.Exec(b.String(), valueArgs...)
+	return err
+}
+
+func (a *activityStore) DeleteForUser(userID string) error {
+	_, err := a.db.ExecContext(context.TODO(), "DELETE FROM Activity WHERE user_id = ?", userID)
+	return err
+}
+
+func (a *activityStore) GetActivitiesForUser(userID string) ([]activity.Activity, error) {
+	rows, err := a.queryActivitiesForUser.Query(userID)
+	if err != nil {
+		return nil, err
+	}
+	var activities []activity.Activity
+
+	var id int
+	var uID string
+	var transportationMode string
+	var startDateTime time.Time
+	var endDateTime time.Time
+
+	for rows.Next() {
+		rows.Scan(&id, &uID, &transportationMode, &startDateTime, &endDateTime)
+		activities = append(activities, activity.Activity{
+			ID: id, UserID: uID, TransportationMode: transportationMode, StartDateTime: startDateTime, EndDateTime: endDateTime,
+		})
+	}
+	return activities, nil
+}
+
+func (a *activityStore) AverageActivitesPerUser() (float64, error) {
+	var avg float64
+	err := a.reader().QueryRowContext(context.TODO(), "SELECT AVG(count) FROM UserActivityCount").Scan(&avg)
+	return avg, err
+}
+
+func (a *activityStore) YearWithMostActivites() (int, int, error) {
+	query := "SELECT YEAR(start_date_time) as year, COUNT(*) AS count FROM Activity GROUP BY YEAR(start_date_time) ORDER BY count DESC LIMIT 1"
+	var count int
+	var year int
+	row := a.reader().QueryRowContext(context.TODO(), query)
+	err := row.Scan(&year, &count)
+	return year, count, err
+}
+
+func (a *activityStore) YearWithMostHours() (int, int, error) {
+	query := "SELECT YEAR(start_date_time) as year, SUM(TIMESTAMPDIFF(hour,start_date_time, end_date_time)) duration FROM Activity GROUP BY YEAR(start_date_time) ORDER BY duration DESC LIMIT 1"
+	var hours int
+	var year int
+	row := a.reader().QueryRowContext(context.TODO(), query)
+	err := row.Scan(&year, &hours)
+	return year, hours, err
+}
+
+func (a *activityStore) GetActivityCountsByYear() ([]activity.YearTotal, error) {
+	rows, err := a.reader().QueryContext(context.TODO(), "SELECT YEAR(start_date_time), COUNT(*) FROM Activity GROUP BY 1 ORDER BY 1")
+	if err != nil {
+		return nil, err
+	}
+	return scanYears(rows)
+}
+
+// GetHoursByYear truncates each activity to whole hours like YearWithMostHours.
+func (a *activityStore) GetHoursByYear() ([]activity.YearTotal, error) {
+	rows, err := a.reader().QueryContext(context.TODO(), "SELECT YEAR(start_date_time), SUM(TIMESTAMPDIFF(hour, start_date_time, end_date_time)) FROM Activity GROUP BY 1 ORDER BY 1")
+	if err != nil {
+		return nil, err
+	}
+	return scanYears(rows)
+}
+
+func scanYears(rows *sql.Rows) ([]activity.YearTotal, error) {
+	defer rows.Close()
+
+	var years []activity.YearTotal
+	for rows.Next() {
+		var year activity.YearTotal
+		if err := rows.Scan(&year.Year, &year.Total); err != nil {
+			return nil, err
+		}
+		years = append(years, year)
+	}
+	return years, rows.Err()
+}
+
+func (a *activityStore) GetActivityIDForUserWithTimestamp(userID string, timeStamp time.Time) (*int, error) {
+	rows, err := a.queryActivityIDWithTimeStamp.Query(timeStamp)
+	if err != nil {
+		return nil, err
+	}
+
+	var id *int
+
+	for rows.Next() {
+		rows.Scan(&id)
+		return id, nil
+	}
+
+	return nil, nil
+}
+
+func (a *activityStore) GetActivity() {
+
+}
+
+func (a *activityStore) Close() {
+	// a.insertActivityStmt.Close()
+}
+
+func (t *activityStore) GetCount() (int, error) {
+	row := t.reader().QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM Activity")
+	var count int
+	row.Scan(&count)
+	return count, nil
+}
+
+// GetUsersActivityCount
+func (a *activityStore) GetUsersActivityCount(limit int) ([]activity.UserCount, error) {
+	var rows *sql.Rows
+	var err error
+	var users []activity.UserCount
+	if limit == -1 {
+		rows, err = a.reader().QueryContext(context.TODO(), "SELECT user_id, COUNT(user_id) FROM Activity GROUP BY user_id ORDER BY 2 DESC")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rows, err = a.reader().QueryContext(context.TODO(), "SELECT user_id, COUNT(user_id) FROM Activity GROUP BY user_id ORDER BY 2 DESC LIMIT ?", limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for rows.Next() {
+		var user activity.UserCount
+		rows.Scan(&user.UserID, &user.Activities)
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (a *activityStore) GetTopTransportationByUsers() ([]activity.TopMode, error) {
+	rows, err := a.reader().QueryContext(context.TODO(), `SELECT u.id, a.transportation_mode, COUNT(a.transportation_mode) as ActivityCount 
+		FROM User as u INNER JOIN Activity as a 
+		ON u.id=a.user_id 
+		GROUP BY u.id, a.transportation_mode 
+		ORDER BY u.id, ActivityCount DESC`)
+	if err != nil {
+		return nil, err
+	}
+
+	var top []activity.TopMode
+
+	var previousUser string
+
+	for rows.Next() {
+		var mode activity.TopMode
+		rows.Scan(&mode.UserID, &mode.TransportationMode, &mode.Activities)
+
+		if previousUser == mode.UserID {
+			continue
+		}
+
+		previousUser = mode.UserID
+		top = append(top, mode)
+	}
+
+	return top, nil
+}
+
+func (a *activityStore) GetTransportationCounts() ([]activity.ModeCount, error) {
+	rows, err := a.reader().QueryContext(context.TODO(), "SELECT transportation_mode, COUNT(transportation_mode) FROM Activity GROUP BY transportation_mode ORDER BY 2 DESC")
+	if err != nil {
+		return nil, err
+	}
+	var transMode string
+	var count int
+
+	var modes []activity.ModeCount
+
+	for rows.Next() {
+		rows.Scan(&transMode, &count)
+		modes = append(modes, activity.ModeCount{TransportationMode: transMode, Activities: count})
+	}
+
+	return modes, nil
+}
+
+func (a *activityStore) GetDistanceByUser(userId, transportationMode string, year int) (float64, error) {
+	activityRows, err := a.reader().QueryContext(context.TODO(), `SELECT a.id, a.transportation_mode, t.lat, t.lon, t.date_time as date FROM Activity as a 
+		INNER JOIN Trackpoint as t ON a.id=t.activity_id 
+		AND a.user_id = ? 
+		AND a.transportation_mode = ? 
+		AND YEAR(a.start_date_time) = ? 
+		ORDER BY t.date_time`, userId, transportationMode, year)
+	if err != nil {
+		return 0, err
+	}
+
+	var transMode string
+	var lat float64
+	var lon float64
+	var date time.Time
+	var activityID string
+
+	prevLat := -999.0
+	prevLon := -999.0
+
+	distance := 0.0
+	var currentActivityID string
+
+	for activityRows.Next() {
+		activityRows.Scan(&activityID, &transMode, &lat, &lon, &date)
+
+		if activityID != currentActivityID || prevLat == -999.0 {
+			prevLat = lat
+			prevLon = lon
+			currentActivityID = activityID
+			continue
+		}
+		distance += activity.Distance(prevLat, prevLon, lat, lon)
+		prevLat = lat
+		prevLon = lon
+	}
+
+	return distance, nil
+}
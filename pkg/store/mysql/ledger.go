@@ -0,0 +1,111 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+)
+
+type ledgerStore struct {
+	db                   *sql.DB
+	upsertEntryStmt      *sql.Stmt
+	queryEntriesForUser  *sql.Stmt
+	deleteEntriesForUser *sql.Stmt
+}
+
+func (l *ledgerStore) LoadStatements() error {
+	upsertEntryStmt, err := l.db.PrepareContext(context.TODO(), `INSERT INTO IngestionLedger(path, user_id, kind, size, mod_time, checksum, row_count, status, error, size_policy, max_points, updated_at)
+		VALUES( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )
+		ON DUPLICATE KEY UPDATE size = VALUES(size), mod_time = VALUES(mod_time), checksum = VALUES(checksum),
+		row_count = VALUES(row_count), status = VALUES(status), error = VALUES(error),
+		size_policy = VALUES(size_policy), max_points = VALUES(max_points), updated_at = VALUES(updated_at)`)
+	if err != nil {
+		return err
+	}
+	queryEntriesForUser, err := l.db.PrepareContext(context.TODO(), "SELECT path, user_id, kind, size, mod_time, checksum, row_count, status, error, size_policy, max_points FROM IngestionLedger WHERE user_id = ?")
+	if err != nil {
+		return err
+	}
+	deleteEntriesForUser, err := l.db.PrepareContext(context.TODO(), "DELETE FROM IngestionLedger WHERE user_id = ?")
+	if err != nil {
+		return err
+	}
+	l.upsertEntryStmt = upsertEntryStmt
+	l.queryEntriesForUser = queryEntriesForUser
+	l.deleteEntriesForUser = deleteEntriesForUser
+	return nil
+}
+
+// GetEntriesForUser returns the recorded files of a user keyed by path.
+func (l *ledgerStore) GetEntriesForUser(userID string) (map[string]ledger.Entry, error) {
+	rows, err := l.queryEntriesForUser.QueryContext(context.TODO(), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make(map[string]ledger.Entry)
+	for rows.Next() {
+		var e ledger.Entry
+		var modTime int64
+		var errMsg sql.NullString
+		if err := rows.Scan(&e.Path, &e.UserID, &e.Kind, &e.Size, &modTime, &e.Checksum, &e.RowCount, &e.Status, &errMsg, &e.SizePolicy, &e.MaxPoints); err != nil {
+			return nil, err
+		}
+		e.ModTime = time.Unix(0, modTime)
+		e.Error = errMsg.String
+		entries[e.Path] = e
+	}
+	return entries, rows.Err()
+}
+
+// RecordTx marks the entries as done as part of tx, so that they are recorded
+// together with the rows loaded from their files. The row count of each entry
+// has to be set by the caller.
+func (l *ledgerStore) RecordTx(tx *sql.Tx, entries ...ledger.Entry) error {
+	stmt := tx.Stmt(l.upsertEntryStmt)
+	for _, entry := range entries {
+		entry.Status = ledger.DONE
+		entry.Error = ""
+		if err := l.upsert(stmt, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Skip records a file that was deliberately not loaded.
+func (l *ledgerStore) Skip(entry ledger.Entry) error {
+	entry.RowCount = 0
+	entry.Status = ledger.SKIPPED
+	entry.Error = ""
+	return l.upsert(l.upsertEntryStmt, entry)
+}
+
+// DeleteForUser forgets every recorded file of a user.
+func (l *ledgerStore) DeleteForUser(userID string) error {
+	_, err := l.deleteEntriesForUser.ExecContext(context.TODO(), userID)
+	return err
+}
+
+// Fail records that loading the file failed with cause and returns cause.
+func (l *ledgerStore) Fail(entry ledger.Entry, cause error) error {
+	entry.RowCount = 0
+	entry.Status = ledger.FAILED
+	entry.Error = cause.Error()
+	if err := l.upsert(l.upsertEntryStmt, entry); err != nil {
+		return err
+	}
+	return cause
+}
+
+func (l *ledgerStore) upsert(stmt *sql.Stmt, e ledger.Entry) error {
+	var errMsg *string
+	if e.Error != "" {
+		errMsg = &e.Error
+	}
+	_, err := stmt.ExecContext(context.TODO(), e.Path, e.UserID, e.Kind, e.Size, e.ModTime.UnixNano(), e.Checksum, e.RowCount, e.Status, errMsg, e.SizePolicy, e.MaxPoints, time.Now())
+	return err
+}
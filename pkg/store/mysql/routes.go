@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/spacycoder/db_mysql/pkg/store/shard"
+)
+
+// errNoSuchTable is the number of the MySQL error of a missing table.
+const errNoSuchTable = 1146
+
+var _ shard.RoutingTable = (*RoutingTable)(nil)
+
+// RoutingTable keeps the routes of the users that move between shards in the
+// ShardRoute table of the store, so that every process reading the shards sees
+// them.
+type RoutingTable struct {
+	db *sql.DB
+}
+
+// RoutingTable returns the routing table in the database of s.
+func (s *Store) RoutingTable() *RoutingTable {
+	return &RoutingTable{db: s.db}
+}
+
+func (r *RoutingTable) Routes() ([]shard.Route, error) {
+	rows, err := r.db.QueryContext(context.TODO(), "SELECT user_id, source, target, state FROM ShardRoute ORDER BY user_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []shard.Route
+	for rows.Next() {
+		var route shard.Route
+		if err := rows.Scan(&route.UserID, &route.Source, &route.Target, &route.State); err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	return routes, rows.Err()
+}
+
+// Len returns the number of routes, 0 if the ShardRoute table does not exist
+// because the store has not been migrated yet.
+func (r *RoutingTable) Len() (int, error) {
+	var count int
+	err := r.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM ShardRoute").Scan(&count)
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errNoSuchTable {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (r *RoutingTable) Get(userID string) (shard.Route, bool, error) {
+	route := shard.Route{UserID: userID}
+	err := r.db.QueryRowContext(context.TODO(), "SELECT source, target, state FROM ShardRoute WHERE user_id = ?", userID).Scan(&route.Source, &route.Target, &route.State)
+	if err == sql.ErrNoRows {
+		return shard.Route{}, false, nil
+	}
+	if err != nil {
+		return shard.Route{}, false, err
+	}
+	return route, true, nil
+}
+
+func (r *RoutingTable) Put(routes ...shard.Route) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO ShardRoute(user_id, source, target, state, updated_at) VALUES( ?, ?, ?, ?, ? )
+		ON DUPLICATE KEY UPDATE source = VALUES(source), target = VALUES(target), state = VALUES(state), updated_at = VALUES(updated_at)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, route := range routes {
+		if _, err := stmt.Exec(route.UserID, route.Source, route.Target, route.State, now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *RoutingTable) Delete(userID string) error {
+	_, err := r.db.ExecContext(context.TODO(), "DELETE FROM ShardRoute WHERE user_id = ?", userID)
+	return err
+}
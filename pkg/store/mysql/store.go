@@ -0,0 +1,155 @@
+// Package mysql implements the store interfaces on MySQL.
+package mysql
+
+import (
+	"database/sql"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/migrations"
+	"github.com/spacycoder/db_mysql/pkg/replica"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+// Trackpoint write strategies.
+const (
+	StrategyInsert   = "insert"
+	StrategyLoadData = "loaddata"
+)
+
+type Options struct {
+	// Strategy is how trackpoints are written, StrategyInsert by default.
+	Strategy string
+	// Replicas are the read replicas of db the task queries are sent to, if set.
+	// Writes and the reads of the loader stay on db.
+	Replicas *replica.Set
+}
+
+func New(db *sql.DB, opts Options) (*Store, error) {
+	userService := &userStore{db: db}
+	activityService := &activityStore{db: db}
+	trackpointService := &trackpointStore{db: db}
+	ledgerService := &ledgerStore{db: db}
+
+	migrator, err := migrations.New(db, migrations.DialectMySQL, migrations.MySQL)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Replicas != nil {
+		userService.SetReplicas(opts.Replicas)
+		activityService.SetReplicas(opts.Replicas)
+		trackpointService.SetReplicas(opts.Replicas)
+	}
+
+	return &Store{
+		db:          db,
+		opts:        opts,
+		users:       userService,
+		activities:  activityService,
+		trackpoints: trackpointService,
+		ledger:      ledgerService,
+		migrator:    migrator,
+	}, nil
+}
+
+var _ store.Store = (*Store)(nil)
+
+type Store struct {
+	db          *sql.DB
+	opts        Options
+	users       *userStore
+	activities  *activityStore
+	trackpoints *trackpointStore
+	ledger      *ledgerStore
+	migrator    *migrations.Migrator
+}
+
+func (s *Store) Users() store.UserStore {
+	return s.users
+}
+
+func (s *Store) Activities() store.ActivityStore {
+	return s.activities
+}
+
+func (s *Store) Trackpoints() store.TrackpointStore {
+	return s.trackpoints
+}
+
+func (s *Store) Ledger() store.LedgerStore {
+	return s.ledger
+}
+
+func (s *Store) Migrator() store.Migrator {
+	return s.migrator
+}
+
+func (s *Store) Prepare() error {
+	if err := s.activities.LoadStatements(); err != nil {
+		return err
+	}
+
+	if err := s.trackpoints.LoadStatements(); err != nil {
+		return err
+	}
+
+	if err := s.users.LoadStatements(); err != nil {
+		return err
+	}
+
+	return s.ledger.LoadStatements()
+}
+
+func (s *Store) Begin() (store.Tx, error) {
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &tx{tx: sqlTx, store: s}, nil
+}
+
+func (s *Store) Close() error {
+	if s.opts.Replicas != nil {
+		if err := s.opts.Replicas.Close(); err != nil {
+			s.db.Close()
+			return err
+		}
+	}
+	return s.db.Close()
+}
+
+type tx struct {
+	tx    *sql.Tx
+	store *Store
+}
+
+func (t *tx) CreateActivities(activities []activity.Activity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+	return t.store.activities.BulkCreateActivityTx(t.tx, activities, len(activities))
+}
+
+func (t *tx) InsertTrackpoints(trackpoints []trackpoint.Trackpoint) error {
+	if len(trackpoints) == 0 {
+		return nil
+	}
+	if t.store.opts.Strategy == StrategyLoadData {
+		return t.store.trackpoints.LoadDataTrackpointTx(t.tx, trackpoints, len(trackpoints))
+	}
+	return t.store.trackpoints.BulkInsertTrackpointTx(t.tx, trackpoints, len(trackpoints))
+}
+
+func (t *tx) Record(entries ...ledger.Entry) error {
+	return t.store.ledger.RecordTx(t.tx, entries...)
+}
+
+func (t *tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *tx) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,256 @@
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/spacycoder/db_mysql/pkg/replica"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+// maxPlaceholders is the maximum number of placeholders in a prepared statement.
+const maxPlaceholders = 65535
+
+const columnCount = 9
+
+// pointFromText is the value of the location column of a trackpoint, computed
+// from a placeholder for its pointWKT.
+const pointFromText = "ST_PointFromText(?, 4326, 'axis-order=lat-long')"
+
+// pointWKT returns a point in well-known text with latitude first, which is the
+// axis order of SRID 4326 and the order in which pointFromText reads it.
+func pointWKT(lat, lon float64) string {
+	buf := make([]byte, 0, 48)
+	buf = append(buf, "POINT("...)
+	buf = strconv.AppendFloat(buf, lat, 'f', -1, 64)
+	buf = append(buf, ' ')
+	buf = strconv.AppendFloat(buf, lon, 'f', -1, 64)
+	return string(append(buf, ')'))
+}
+
+type trackpointStore struct {
+	db                   *sql.DB
+	replicas             *replica.Set
+	insertTrackpointStmt *sql.Stmt
+	// statementRows is the maximum number of trackpoints in one INSERT statement
+	statementRows int
+}
+
+// SetReplicas sends the read-only queries of the tasks to the replicas of set.
+func (t *trackpointStore) SetReplicas(set *replica.Set) {
+	t.replicas = set
+}
+
+// reader returns the database a read-only query of a task runs on.
+func (t *trackpointStore) reader() *sql.DB {
+	if t.replicas == nil {
+		return t.db
+	}
+	return t.replicas.Reader()
+}
+
+func (t *trackpointStore) LoadStatements() error {
+	insertTrackpointStmt, err := t.db.PrepareContext(context.TODO(), "INSERT INTO Trackpoint(activity_id, user_id, lat, lon, location, altitude, altitude_raw, date_days, date_time) VALUES( ?, ?, ?, ?, "+pointFromText+", ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	var maxAllowedPacket int
+	err = t.db.QueryRowContext(context.TODO(), "SELECT @@max_allowed_packet").Scan(&maxAllowedPacket)
+	if err != nil {
+		return err
+	}
+
+	t.insertTrackpointStmt = insertTrackpointStmt
+	t.statementRows = maxPlaceholders / columnCount
+	if rows := maxAllowedPacket / trackpoint.RowBytes; rows < t.statementRows {
+		t.statementRows = rows
+	}
+	return nil
+}
+
+func (t *trackpointStore) GetCount() (int, error) {
+	row := t.reader().QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM Trackpoint")
+	var count int
+	row.Scan(&count)
+	return count, nil
+}
+
+func (t *trackpointStore) GetCountForUser(userID string) (int, error) {
+	var count int
+	err := t.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM Trackpoint WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+// GetForUser returns at most limit trackpoints of a user with an id above
+// afterID, by id.
+func (t *trackpointStore) GetForUser(userID string, afterID, limit int) ([]trackpoint.Trackpoint, error) {
+	return queryTrackpoints(t.db, `SELECT id, activity_id, user_id, lat, lon, altitude, altitude_raw, date_days, date_time
+		FROM Trackpoint WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?`, userID, afterID, limit)
+}
+
+// GetAround finds the trackpoints in the box around lat and lon with the
+// spatial index on location, on a replica if there are any.
+func (t *trackpointStore) GetAround(userID string, lat, lon, span float64, limit int) ([]trackpoint.Trackpoint, error) {
+	box := boxWKT(lat-span, lon-span, lat+span, lon+span)
+	return queryTrackpoints(t.reader(), `SELECT id, activity_id, user_id, lat, lon, altitude, altitude_raw, date_days, date_time
+		FROM Trackpoint WHERE user_id = ? AND MBRIntersects(ST_GeomFromText(?, 4326, 'axis-order=lat-long'), location)
+		ORDER BY id LIMIT ?`, userID, box, limit)
+}
+
+func queryTrackpoints(db *sql.DB, query string, args ...interface{}) ([]trackpoint.Trackpoint, error) {
+	rows, err := db.QueryContext(context.TODO(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanTrackpoints(rows)
+}
+
+func (t *trackpointStore) CreateTrackpoint(activityID *int, userID string, lat, lon float64, altitude *float64, altitudeRaw float64, dateDays float64, datetime time.Time) error {
+	_, err := t.insertTrackpointStmt.Exec(activityID, userID, lat, lon, pointWKT(lat, lon), altitude, altitudeRaw, dateDays, datetime)
+	return err
+}
+
+func (t *trackpointStore) BulkInsertTrackpoint(trackpoints []trackpoint.Trackpoint, numTrackpoints int) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = t.BulkInsertTrackpointTx(tx, trackpoints, numTrackpoints)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// BulkInsertTrackpointTx inserts the first numTrackpoints trackpoints as part of
+// tx, using as few INSERT statements as the placeholder limit and
+// max_allowed_packet allow.
+func (t *trackpointStore) BulkInsertTrackpointTx(tx *sql.Tx, trackpoints []trackpoint.Trackpoint, numTrackpoints int) error {
+	trackpoints = trackpoints[:numTrackpoints]
+	for len(trackpoints) > 0 {
+		n := len(trackpoints)
+		if n > t.statementRows {
+			n = t.statementRows
+		}
+		if err := t.insertStatement(tx, trackpoints, n); err != nil {
+			return err
+		}
+		trackpoints = trackpoints[n:]
+	}
+	return nil
+}
+
+func (t *trackpointStore) insertStatement(tx *sql.Tx, trackpoints []trackpoint.Trackpoint, numTrackpoints int) error {
+	valueArgs := make([]interface{}, numTrackpoints*columnCount, numTrackpoints*columnCount)
+
+	var b strings.Builder
+	row := "(?, ?, ?, ?, " + pointFromText + ", ?, ?, ?, ?)"
+	b.Grow((len(row) + 1) * numTrackpoints)
+
+	fmt.Fprintf(&b, "INSERT INTO Trackpoint(activity_id, user_id, lat, lon, location, altitude, altitude_raw, date_days, date_time) VALUES ")
+	for i := 0; i < numTrackpoints; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(row)
+
+		t := trackpoints[i]
+		index := i * columnCount
+		valueArgs[index] = t.ActivityID
+		valueArgs[index+1] = t.UserID
+		valueArgs[index+2] = t.Lat
+		valueArgs[index+3] = t.Lon
+		valueArgs[index+4] = pointWKT(t.Lat, t.Lon)
+		valueArgs[index+5] = t.Altitude
+		valueArgs[index+6] = t.AltitudeRaw
+		valueArgs[index+7] = t.DateDays
+		valueArgs[index+8] = t.DateTime
+	}
+
+	_, err := tx.Exec(b.String(), valueArgs...)
+	return err
+}
+
+// readerHandlerID makes the names of registered LOAD DATA reader handlers unique.
+var readerHandlerID uint64
+
+// LoadDataTrackpointTx streams the first numTrackpoints trackpoints to the server
+// with LOAD DATA LOCAL INFILE as part of tx. The server has to run with
+// local_infile enabled. The location is computed from lat and lon on the server.
+func (t *trackpointStore) LoadDataTrackpointTx(tx *sql.Tx, trackpoints []trackpoint.Trackpoint, numTrackpoints int) error {
+	name := fmt.Sprintf("trackpoints-%d", atomic.AddUint64(&readerHandlerID, 1))
+	mysqldriver.RegisterReaderHandler(name, func() io.Reader {
+		r, w := io.Pipe()
+		go func() {
+			w.CloseWithError(writeRows(w, trackpoints[:numTrackpoints]))
+		}()
+		return r
+	})
+	defer mysqldriver.DeregisterReaderHandler(name)
+
+	query := `LOAD DATA LOCAL INFILE 'Reader::` + name + `' INTO TABLE Trackpoint
+		FIELDS TERMINATED BY '\t' LINES TERMINATED BY '\n'
+		(activity_id, user_id, @lat, @lon, altitude, altitude_raw, date_days, date_time)
+		SET lat = @lat, lon = @lon, location = ST_PointFromText(CONCAT('POINT(', @lat, ' ', @lon, ')'), 4326, 'axis-order=lat-long')`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// writeRows writes trackpoints as tab separated rows in the column order of
+// LoadDataTrackpointTx, with \N for NULL.
+func writeRows(w io.Writer, trackpoints []trackpoint.Trackpoint) error {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, 128)
+	for _, t := range trackpoints {
+		buf = buf[:0]
+		if t.ActivityID == nil {
+			buf = append(buf, `\N`...)
+		} else {
+			buf = strconv.AppendInt(buf, int64(*t.ActivityID), 10)
+		}
+		buf = append(buf, '\t')
+		buf = append(buf, t.UserID...)
+		buf = append(buf, '\t')
+		buf = strconv.AppendFloat(buf, t.Lat, 'f', -1, 64)
+		buf = append(buf, '\t')
+		buf = strconv.AppendFloat(buf, t.Lon, 'f', -1, 64)
+		buf = append(buf, '\t')
+		if t.Altitude == nil {
+			buf = append(buf, `\N`...)
+		} else {
+			buf = strconv.AppendFloat(buf, *t.Altitude, 'f', -1, 64)
+		}
+		buf = append(buf, '\t')
+		buf = strconv.AppendFloat(buf, t.AltitudeRaw, 'f', -1, 64)
+		buf = append(buf, '\t')
+		buf = strconv.AppendFloat(buf, t.DateDays, 'f', -1, 64)
+		buf = append(buf, '\t')
+		buf = t.DateTime.AppendFormat(buf, "2006-01-02 15:04:05")
+		buf = append(buf, '\n')
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (t *trackpointStore) DeleteForUser(userID string) error {
+	_, err := t.db.ExecContext(context.TODO(), "DELETE FROM Trackpoint WHERE user_id = ?", userID)
+	return err
+}
+
+func (t *trackpointStore) Close() {
+
+}
@@ -0,0 +1,315 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/spacycoder/db_mysql/pkg/replica"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+func (u *userStore) LoadStatements() error {
+	userInsertStmt, err := u.db.PrepareContext(context.TODO(), "INSERT INTO User(id, has_labels) VALUES( ?, ? ) ON DUPLICATE KEY UPDATE has_labels = VALUES(has_labels)")
+	if err != nil {
+		return err
+	}
+	u.userInsertStmt = userInsertStmt
+	return nil
+}
+
+type userStore struct {
+	db             *sql.DB
+	replicas       *replica.Set
+	userInsertStmt *sql.Stmt
+}
+
+// SetReplicas sends the read-only queries of the tasks to the replicas of set.
+func (u *userStore) SetReplicas(set *replica.Set) {
+	u.replicas = set
+}
+
+// reader returns the database a read-only query of a task runs on.
+func (u *userStore) reader() *sql.DB {
+	if u.replicas == nil {
+		return u.db
+	}
+	return u.replicas.Reader()
+}
+
+func (u *userStore) GetUsers() ([]user.User, error) {
+	return getUsers(u.db)
+}
+
+func getUsers(db *sql.DB) ([]user.User, error) {
+	var users []user.User
+	rows, err := db.QueryContext(context.TODO(), "SELECT * FROM User")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var usr string
+	var hasLabel bool
+
+	for rows.Next() {
+		if err := rows.Scan(&usr, &hasLabel); err != nil {
+			return nil, err
+		}
+		users = append(users, user.User{
+			ID:        usr,
+			HasLabels: hasLabel,
+		})
+	}
+
+	return users, rows.Err()
+}
+
+func (u *userStore) GetUsersThatHasUsedTransportationMode(transportationMode string) ([]string, error) {
+	queryTransportation, err := u.reader().PrepareContext(context.TODO(), "SELECT DISTINCT a.user_id FROM Activity a WHERE a.transportation_mode = (?)")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := queryTransportation.QueryContext(context.TODO(), transportationMode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []string{}
+	for rows.Next() {
+		var usr string
+		if err := rows.Scan(&usr); err != nil {
+			return nil, err
+		}
+
+		users = append(users, usr)
+	}
+
+	return users, err
+}
+
+func (u *userStore) GetCount() (int, error) {
+	row := u.reader().QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM User")
+	var count int
+	row.Scan(&count)
+	return count, nil
+}
+
+func (u *userStore) GetUsersWithActivities() ([]user.User, error) {
+	query := `SELECT * FROM User WHERE has_labels = true`
+	stmt, err := u.db.PrepareContext(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	users := []user.User{}
+	for rows.Next() {
+		var usr user.User
+		rows.Scan(&usr.ID, &usr.HasLabels)
+		users = append(users, usr)
+	}
+
+	return users, nil
+}
+
+// GetUsersWithMostAltitude adds up the altitude every user gained walking, with
+// one query per user in parallel, and returns the numUsers users that gained
+// the most. It returns the error of the first user whose query failed.
+func (u *userStore) GetUsersWithMostAltitude(numUsers int) ([]user.UserWithAltitude, error) {
+	db := u.reader()
+	users, err := getUsers(db)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT t.altitude, t.activity_id FROM Trackpoint t INNER JOIN Activity a ON t.activity_id=a.id AND a.transportation_mode="Walk" AND t.altitude IS NOT NULL AND a.user_id=? ORDER BY t.date_time, t.activity_id`
+	stmt, err := db.PrepareContext(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	usersWithAltitude := make([]user.UserWithAltitude, len(users), len(users))
+	errs := make([]error, len(users))
+	var wg sync.WaitGroup
+	for i, u := range users {
+		wg.Add(1)
+		go func(u user.User, index int) {
+			defer wg.Done()
+			gained, err := gainedAltitude(stmt, u.ID)
+			usersWithAltitude[index] = user.UserWithAltitude{
+				UserID:         u.ID,
+				GainedAltitude: gained,
+			}
+			errs[index] = err
+		}(u, i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", users[i].ID, err)
+		}
+	}
+
+	sort.Slice(usersWithAltitude, func(i, j int) bool {
+		return usersWithAltitude[i].GainedAltitude > usersWithAltitude[j].GainedAltitude
+	})
+	if len(usersWithAltitude) > numUsers {
+		usersWithAltitude = usersWithAltitude[0:numUsers]
+	}
+	return usersWithAltitude, nil
+}
+
+// gainedAltitude adds up the altitude the user gained walking, with stmt
+// returning the altitudes of the walks of the user.
+func gainedAltitude(stmt *sql.Stmt, userID string) (float64, error) {
+	rows, err := stmt.QueryContext(context.TODO(), userID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	gainedAltitude := 0.0
+	prevAltitude := 0.0
+	currentActivityId := -1
+	for rows.Next() {
+		var altitude float64
+		var id int
+		if err := rows.Scan(&altitude, &id); err != nil {
+			return 0, err
+		}
+		if id != currentActivityId {
+			currentActivityId = id
+			prevAltitude = altitude
+			continue
+		}
+		if altitude > prevAltitude {
+			gainedAltitude += altitude - prevAltitude
+		}
+		prevAltitude = altitude
+	}
+	return gainedAltitude, rows.Err()
+}
+
+// aroundBox is the distance in degrees around a position that UsersAround searches.
+const aroundBox = 0.001
+
+// metersPerDegree is the length of a degree of latitude on the sphere of
+// ST_Distance_Sphere.
+const metersPerDegree = 6370986 * math.Pi / 180
+
+// UsersAround returns the users with a trackpoint in the box of aroundBox
+// degrees around lat and lon, found with the spatial index on location.
+func (u *userStore) UsersAround(lat, lon float64) ([]string, error) {
+	box := boxWKT(lat-aroundBox, lon-aroundBox, lat+aroundBox, lon+aroundBox)
+	return u.queryUsers("SELECT DISTINCT user_id FROM Trackpoint WHERE MBRIntersects(ST_GeomFromText(?, 4326, 'axis-order=lat-long'), location) ORDER BY user_id", box)
+}
+
+// UsersNear returns the users with a trackpoint at most meters away from lat and
+// lon. The spatial index narrows the trackpoints down to a bounding box around
+// the circle, and ST_Distance_Sphere measures the distance of the ones in it.
+func (u *userStore) UsersNear(lat, lon, meters float64) ([]string, error) {
+	dLat := meters / metersPerDegree
+	dLon := 180.0
+	if cos := math.Cos(lat * math.Pi / 180); dLat/cos < 180 {
+		dLon = dLat / cos
+	}
+	box := boxWKT(math.Max(lat-dLat, -90), math.Max(lon-dLon, -180), math.Min(lat+dLat, 90), math.Min(lon+dLon, 180))
+
+	query := `SELECT DISTINCT user_id FROM Trackpoint
+		WHERE MBRIntersects(ST_GeomFromText(?, 4326, 'axis-order=lat-long'), location)
+		AND ST_Distance_Sphere(location, ST_PointFromText(?, 4326, 'axis-order=lat-long')) <= ?
+		ORDER BY user_id`
+	return u.queryUsers(query, box, pointWKT(lat, lon), meters)
+}
+
+func (u *userStore) queryUsers(query string, args ...interface{}) ([]string, error) {
+	rows, err := u.reader().QueryContext(context.TODO(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []string{}
+	for rows.Next() {
+		var usr string
+		if err := rows.Scan(&usr); err != nil {
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+	return users, rows.Err()
+}
+
+// boxWKT returns the rectangle between two corners as a polygon in well-known
+// text with latitude first, its ring counterclockwise.
+func boxWKT(minLat, minLon, maxLat, maxLon float64) string {
+	south, west := formatFloat(minLat), formatFloat(minLon)
+	north, east := formatFloat(maxLat), formatFloat(maxLon)
+	return "POLYGON((" + south + " " + west + ", " + south + " " + east + ", " + north + " " + east + ", " +
+		north + " " + west + ", " + south + " " + west + "))"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (u *userStore) CreateUser(id string, hasLabels bool) error {
+	_, err := u.userInsertStmt.ExecContext(context.TODO(), id, hasLabels)
+	return err
+}
+
+func (u *userStore) DeleteUser(id string) error {
+	_, err := u.db.ExecContext(context.TODO(), "DELETE FROM User WHERE id = ?", id)
+	return err
+}
+
+func (u *userStore) GetUser(id string) {
+
+}
+
+func (u *userStore) GetUsersWithInvalidActivites() ([]user.UserWithInvalidActivities, error) {
+	query := `SELECT invalid.user_id, COUNT(*) FROM (SELECT DISTINCT user_id, activity_id FROM (
+		SELECT
+		t.*,
+		@prev_act AS previous_act,
+		@prev_date AS prev_date,
+		IF(@prev_act = t.activity_id, ABS(TIMESTAMPDIFF(MINUTE, @prev_date, t.date_time)), 4) as diff,
+		@prev_date := t.date_time,
+		@prev_act := t.activity_id
+		FROM Trackpoint t,
+		(SELECT @prev_date := null, @prev_act := null) var_init
+		ORDER BY t.date_time, t.activity_id) sq
+		WHERE activity_id IS NOT NULL AND diff > 4) AS invalid
+	GROUP BY invalid.user_id`
+
+	stmt, err := u.reader().PrepareContext(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	users := []user.UserWithInvalidActivities{}
+	for rows.Next() {
+		var usr user.UserWithInvalidActivities
+		rows.Scan(&usr.UserID, &usr.InvalidActivities)
+
+		users = append(users, usr)
+	}
+
+	return users, nil
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/store"
+)
+
+type activityStore struct {
+	db *sql.DB
+}
+
+func (a *activityStore) GetActivitiesForUser(userID string) ([]activity.Activity, error) {
+	rows, err := a.db.QueryContext(context.TODO(), "SELECT id, user_id, transportation_mode, start_date_time, end_date_time FROM activity WHERE user_id = $1 ORDER BY start_date_time ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []activity.Activity
+	for rows.Next() {
+		var act activity.Activity
+		if err := rows.Scan(&act.ID, &act.UserID, &act.TransportationMode, &act.StartDateTime, &act.EndDateTime); err != nil {
+			return nil, err
+		}
+		activities = append(activities, act)
+	}
+	return activities, rows.Err()
+}
+
+func (a *activityStore) DeleteForUser(userID string) error {
+	_, err := a.db.ExecContext(context.TODO(), "DELETE FROM activity WHERE user_id = $1", userID)
+	return err
+}
+
+func (a *activityStore) GetCount() (int, error) {
+	var count int
+	err := a.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM activity").Scan(&count)
+	return count, err
+}
+
+func (a *activityStore) AverageActivitesPerUser() (float64, error) {
+	var avg sql.NullFloat64
+	err := a.db.QueryRowContext(context.TODO(), "SELECT AVG(count) FROM user_activity_count").Scan(&avg)
+	return avg.Float64, err
+}
+
+func (a *activityStore) GetUsersActivityCount(limit int) ([]activity.UserCount, error) {
+	// LIMIT NULL returns every row
+	var max sql.NullInt64
+	if limit != -1 {
+		max = sql.NullInt64{Int64: int64(limit), Valid: true}
+	}
+	rows, err := a.db.QueryContext(context.TODO(), "SELECT user_id, COUNT(user_id) FROM activity GROUP BY user_id ORDER BY 2 DESC, 1 LIMIT $1", max)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanUserCounts(rows)
+}
+
+// GetTransportationCounts groups the modes by lower(transportation_mode), so
+// that they compare case-insensitively like with the MySQL collation, and
+// returns each under the spelling of its first activity.
+func (a *activityStore) GetTransportationCounts() ([]activity.ModeCount, error) {
+	rows, err := a.db.QueryContext(context.TODO(), `SELECT (array_agg(transportation_mode ORDER BY id))[1], COUNT(transportation_mode)
+		FROM activity GROUP BY lower(transportation_mode) ORDER BY 2 DESC, lower(transportation_mode)`)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanModeCounts(rows)
+}
+
+func (a *activityStore) YearWithMostActivites() (int, int, error) {
+	query := "SELECT EXTRACT(YEAR FROM start_date_time)::int AS year, COUNT(*) AS count FROM activity GROUP BY year ORDER BY count DESC LIMIT 1"
+	var year, count int
+	err := a.db.QueryRowContext(context.TODO(), query).Scan(&year, &count)
+	return year, count, err
+}
+
+// YearWithMostHours truncates the duration of each activity to whole hours
+// before summing, like TIMESTAMPDIFF does in the MySQL query.
+func (a *activityStore) YearWithMostHours() (int, int, error) {
+	query := `SELECT EXTRACT(YEAR FROM start_date_time)::int AS year,
+		SUM(TRUNC(EXTRACT(EPOCH FROM end_date_time - start_date_time) / 3600))::bigint AS duration
+		FROM activity GROUP BY year ORDER BY duration DESC LIMIT 1`
+	var year, hours int
+	err := a.db.QueryRowContext(context.TODO(), query).Scan(&year, &hours)
+	return year, hours, err
+}
+
+func (a *activityStore) GetActivityCountsByYear() ([]activity.YearTotal, error) {
+	rows, err := a.db.QueryContext(context.TODO(), "SELECT EXTRACT(YEAR FROM start_date_time)::int AS year, COUNT(*) FROM activity GROUP BY year ORDER BY year")
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanYears(rows)
+}
+
+func (a *activityStore) GetHoursByYear() ([]activity.YearTotal, error) {
+	rows, err := a.db.QueryContext(context.TODO(), `SELECT EXTRACT(YEAR FROM start_date_time)::int AS year,
+		SUM(TRUNC(EXTRACT(EPOCH FROM end_date_time - start_date_time) / 3600))::bigint
+		FROM activity GROUP BY year ORDER BY year`)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanYears(rows)
+}
+
+// GetDistanceByUser joins the trackpoints of each activity into a line
+// with ST_MakeLine and sums the lengths of the lines on the sphere, which is
+// what the haversine formula of the MySQL store computes.
+func (a *activityStore) GetDistanceByUser(userID, transportationMode string, year int) (float64, error) {
+	query := `SELECT COALESCE(SUM(ST_Length(line.path, false)), 0) / 1000 FROM (
+		SELECT ST_MakeLine(t.location::geometry ORDER BY t.date_time)::geography AS path
+		FROM activity a INNER JOIN trackpoint t ON a.id = t.activity_id
+		WHERE a.user_id = $1
+		AND lower(a.transportation_mode) = lower($2)
+		AND EXTRACT(YEAR FROM a.start_date_time) = $3
+		GROUP BY a.id
+		HAVING COUNT(*) > 1
+	) line`
+
+	var distance float64
+	err := a.db.QueryRowContext(context.TODO(), query, userID, transportationMode, year).Scan(&distance)
+	return distance, err
+}
+
+// GetTopTransportationByUsers counts the modes of every user ignoring case, like
+// GetTransportationCounts, and breaks ties with the mode that sorts first.
+func (a *activityStore) GetTopTransportationByUsers() ([]activity.TopMode, error) {
+	rows, err := a.db.QueryContext(context.TODO(), `SELECT DISTINCT ON (u.id) u.id, (array_agg(a.transportation_mode ORDER BY a.id))[1], COUNT(a.transportation_mode) AS activity_count
+		FROM users AS u INNER JOIN activity AS a
+		ON u.id = a.user_id
+		GROUP BY u.id, lower(a.transportation_mode)
+		ORDER BY u.id, activity_count DESC, lower(a.transportation_mode)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var top []activity.TopMode
+	for rows.Next() {
+		var mode activity.TopMode
+		if err := rows.Scan(&mode.UserID, &mode.TransportationMode, &mode.Activities); err != nil {
+			return nil, err
+		}
+		top = append(top, mode)
+	}
+	return top, rows.Err()
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"database/sql"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/store"
+)
+
+// openTestStore opens the scratch database of STRAVA_TEST_POSTGRES with the
+// schema migrated and no activities, or skips the test.
+func openTestStore(t *testing.T) *Store {
+	dsn := os.Getenv("STRAVA_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("STRAVA_TEST_POSTGRES is not set to the connection string of a scratch database")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := New(db)
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	if _, err := st.Migrator().Up(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	count, err := st.Activities().GetCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count > 0 {
+		t.Skip("the database of STRAVA_TEST_POSTGRES has activities")
+	}
+	return st
+}
+
+func TestTransportationModesIgnoreCase(t *testing.T) {
+	st := openTestStore(t)
+
+	start := time.Date(2008, 10, 28, 12, 0, 0, 0, time.UTC)
+	modes := map[string][]string{
+		"010": {"walk", "Walk", "bus"},
+		"011": {"bike", "Bike", "walk"},
+		"012": {"taxi", "bus"},
+	}
+	var activities []activity.Activity
+	for _, userID := range []string{"010", "011", "012"} {
+		if err := st.Users().CreateUser(userID, true); err != nil {
+			t.Fatal(err)
+		}
+		for i, mode := range modes[userID] {
+			begin := start.Add(time.Duration(i) * time.Hour)
+			activities = append(activities, activity.Activity{UserID: userID, TransportationMode: mode, StartDateTime: begin, EndDateTime: begin.Add(time.Minute)})
+		}
+	}
+	t.Cleanup(func() {
+		for userID := range modes {
+			st.Activities().DeleteForUser(userID)
+			st.Users().DeleteUser(userID)
+		}
+	})
+	err := store.InTx(st, func(tx store.Tx) error {
+		return tx.CreateActivities(activities)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := st.Activities().GetTransportationCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCounts := []activity.ModeCount{
+		{TransportationMode: "walk", Activities: 3},
+		{TransportationMode: "bike", Activities: 2},
+		{TransportationMode: "bus", Activities: 2},
+		{TransportationMode: "taxi", Activities: 1},
+	}
+	if !reflect.DeepEqual(counts, wantCounts) {
+		t.Errorf("GetTransportationCounts() = %+v, want %+v", counts, wantCounts)
+	}
+
+	top, err := st.Activities().GetTopTransportationByUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTop := []activity.TopMode{
+		{UserID: "010", TransportationMode: "walk", Activities: 2},
+		{UserID: "011", TransportationMode: "bike", Activities: 2},
+		{UserID: "012", TransportationMode: "bus", Activities: 1},
+	}
+	if !reflect.DeepEqual(top, wantTop) {
+		t.Errorf("GetTopTransportationByUsers() = %+v, want %+v", top, wantTop)
+	}
+}
@@ -0,0 +1,168 @@
+// Package postgres implements the store interfaces on PostgreSQL with PostGIS.
+// Trackpoints are stored as geography points, and distance and proximity
+// queries run in the database with the PostGIS functions.
+package postgres
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/migrations"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+// New returns a store on db, which has to be opened with the "postgres" driver
+// of github.com/lib/pq.
+func New(db *sql.DB) (*Store, error) {
+	migrator, err := migrations.New(db, migrations.DialectPostgres, migrations.Postgres)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		db:          db,
+		users:       &userStore{db: db},
+		activities:  &activityStore{db: db},
+		trackpoints: &trackpointStore{db: db},
+		ledger:      &ledgerStore{db: db},
+		migrator:    migrator,
+	}, nil
+}
+
+var _ store.Store = (*Store)(nil)
+
+type Store struct {
+	db          *sql.DB
+	users       *userStore
+	activities  *activityStore
+	trackpoints *trackpointStore
+	ledger      *ledgerStore
+	migrator    *migrations.Migrator
+}
+
+func (s *Store) Users() store.UserStore {
+	return s.users
+}
+
+func (s *Store) Activities() store.ActivityStore {
+	return s.activities
+}
+
+func (s *Store) Trackpoints() store.TrackpointStore {
+	return s.trackpoints
+}
+
+func (s *Store) Ledger() store.LedgerStore {
+	return s.ledger
+}
+
+func (s *Store) Migrator() store.Migrator {
+	return s.migrator
+}
+
+func (s *Store) Prepare() error {
+	if err := s.users.loadStatements(); err != nil {
+		return err
+	}
+
+	return s.ledger.loadStatements()
+}
+
+func (s *Store) Begin() (store.Tx, error) {
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &tx{tx: sqlTx, store: s}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+type tx struct {
+	tx    *sql.Tx
+	store *Store
+}
+
+func (t *tx) CreateActivities(activities []activity.Activity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+
+	stmt, err := t.tx.Prepare(pq.CopyIn("activity", "user_id", "transportation_mode", "start_date_time", "end_date_time"))
+	if err != nil {
+		return err
+	}
+	for _, a := range activities {
+		if _, err := stmt.Exec(a.UserID, a.TransportationMode, a.StartDateTime, a.EndDateTime); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	return closeCopy(stmt)
+}
+
+// InsertTrackpoints streams the trackpoints with COPY. The location is sent as
+// EWKT text, which the geography column parses on input.
+func (t *tx) InsertTrackpoints(trackpoints []trackpoint.Trackpoint) error {
+	if len(trackpoints) == 0 {
+		return nil
+	}
+
+	stmt, err := t.tx.Prepare(pq.CopyIn("trackpoint", "activity_id", "user_id", "location", "altitude", "altitude_raw", "date_days", "date_time"))
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 0, 64)
+	for _, p := range trackpoints {
+		buf = appendPoint(buf[:0], p.Lat, p.Lon)
+		if _, err := stmt.Exec(p.ActivityID, p.UserID, string(buf), p.Altitude, p.AltitudeRaw, p.DateDays, p.DateTime); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	return closeCopy(stmt)
+}
+
+func (t *tx) Record(entries ...ledger.Entry) error {
+	stmt := t.tx.Stmt(t.store.ledger.upsertStmt)
+	for _, entry := range entries {
+		entry.Status = ledger.DONE
+		entry.Error = ""
+		if err := upsertEntry(stmt, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// closeCopy flushes the rows buffered by a COPY statement and closes it.
+func closeCopy(stmt *sql.Stmt) error {
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	return stmt.Close()
+}
+
+// appendPoint appends a WGS 84 point in EWKT, which has longitude first.
+func appendPoint(buf []byte, lat, lon float64) []byte {
+	buf = append(buf, "SRID=4326;POINT("...)
+	buf = strconv.AppendFloat(buf, lon, 'f', -1, 64)
+	buf = append(buf, ' ')
+	buf = strconv.AppendFloat(buf, lat, 'f', -1, 64)
+	return append(buf, ')')
+}
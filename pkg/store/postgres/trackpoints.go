@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+type trackpointStore struct {
+	db *sql.DB
+}
+
+func (t *trackpointStore) GetCount() (int, error) {
+	var count int
+	err := t.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM trackpoint").Scan(&count)
+	return count, err
+}
+
+func (t *trackpointStore) GetCountForUser(userID string) (int, error) {
+	var count int
+	err := t.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM trackpoint WHERE user_id = $1", userID).Scan(&count)
+	return count, err
+}
+
+func (t *trackpointStore) GetForUser(userID string, afterID, limit int) ([]trackpoint.Trackpoint, error) {
+	rows, err := t.db.QueryContext(context.TODO(), `SELECT id, activity_id, user_id, ST_Y(location::geometry), ST_X(location::geometry), altitude, altitude_raw, date_days, date_time
+		FROM trackpoint WHERE user_id = $1 AND id > $2 ORDER BY id LIMIT $3`, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanTrackpoints(rows)
+}
+
+// GetAround finds the trackpoints in the box around lat and lon with the GiST
+// index on the locations, like UsersAround.
+func (t *trackpointStore) GetAround(userID string, lat, lon, span float64, limit int) ([]trackpoint.Trackpoint, error) {
+	rows, err := t.db.QueryContext(context.TODO(), `SELECT id, activity_id, user_id, ST_Y(location::geometry), ST_X(location::geometry), altitude, altitude_raw, date_days, date_time
+		FROM trackpoint WHERE user_id = $1
+		AND location && ST_MakeEnvelope($2::float8, $3::float8, $4::float8, $5::float8, 4326)::geography
+		AND ST_Intersects(location, ST_MakeEnvelope($2::float8, $3::float8, $4::float8, $5::float8, 4326)::geography)
+		ORDER BY id LIMIT $6`, userID, lon-span, lat-span, lon+span, lat+span, limit)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanTrackpoints(rows)
+}
+
+func (t *trackpointStore) DeleteForUser(userID string) error {
+	_, err := t.db.ExecContext(context.TODO(), "DELETE FROM trackpoint WHERE user_id = $1", userID)
+	return err
+}
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+// aroundBox is the distance in degrees around a position that UsersAround searches.
+const aroundBox = 0.001
+
+type userStore struct {
+	db         *sql.DB
+	insertStmt *sql.Stmt
+}
+
+func (u *userStore) loadStatements() error {
+	insertStmt, err := u.db.PrepareContext(context.TODO(), "INSERT INTO users(id, has_labels) VALUES( $1, $2 ) ON CONFLICT (id) DO UPDATE SET has_labels = EXCLUDED.has_labels")
+	if err != nil {
+		return err
+	}
+	u.insertStmt = insertStmt
+	return nil
+}
+
+func (u *userStore) CreateUser(id string, hasLabels bool) error {
+	_, err := u.insertStmt.ExecContext(context.TODO(), id, hasLabels)
+	return err
+}
+
+func (u *userStore) DeleteUser(id string) error {
+	_, err := u.db.ExecContext(context.TODO(), "DELETE FROM users WHERE id = $1", id)
+	return err
+}
+
+func (u *userStore) GetUsers() ([]user.User, error) {
+	rows, err := u.db.QueryContext(context.TODO(), "SELECT id, has_labels FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []user.User
+	for rows.Next() {
+		var usr user.User
+		if err := rows.Scan(&usr.ID, &usr.HasLabels); err != nil {
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+	return users, rows.Err()
+}
+
+func (u *userStore) GetCount() (int, error) {
+	var count int
+	err := u.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
+
+func (u *userStore) GetUsersThatHasUsedTransportationMode(transportationMode string) ([]string, error) {
+	rows, err := u.db.QueryContext(context.TODO(), "SELECT DISTINCT user_id FROM activity WHERE lower(transportation_mode) = lower($1) ORDER BY user_id", transportationMode)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanIDs(rows)
+}
+
+// GetUsersWithMostAltitude sums the altitude gained between consecutive
+// trackpoints of each walk in the database.
+func (u *userStore) GetUsersWithMostAltitude(numUsers int) ([]user.UserWithAltitude, error) {
+	query := `SELECT u.id, COALESCE(gained.altitude, 0) FROM users u LEFT JOIN (
+		SELECT user_id, SUM(GREATEST(altitude - prev_altitude, 0)) AS altitude FROM (
+			SELECT a.user_id, t.altitude,
+			LAG(t.altitude) OVER (PARTITION BY t.activity_id ORDER BY t.date_time) AS prev_altitude
+			FROM trackpoint t INNER JOIN activity a ON t.activity_id = a.id
+			WHERE lower(a.transportation_mode) = 'walk' AND t.altitude IS NOT NULL
+		) climbs
+		WHERE prev_altitude IS NOT NULL
+		GROUP BY user_id
+	) gained ON gained.user_id = u.id
+	ORDER BY 2 DESC, 1
+	LIMIT $1`
+
+	rows, err := u.db.QueryContext(context.TODO(), query, numUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []user.UserWithAltitude
+	for rows.Next() {
+		var usr user.UserWithAltitude
+		if err := rows.Scan(&usr.UserID, &usr.GainedAltitude); err != nil {
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+	return users, rows.Err()
+}
+
+// GetUsersWithInvalidActivites counts the activities per user in which two
+// consecutive trackpoints are at least five minutes apart.
+func (u *userStore) GetUsersWithInvalidActivites() ([]user.UserWithInvalidActivities, error) {
+	query := `SELECT user_id, COUNT(*) FROM (
+		SELECT DISTINCT user_id, activity_id FROM (
+			SELECT user_id, activity_id, date_time,
+			LAG(activity_id) OVER w AS prev_act,
+			LAG(date_time) OVER w AS prev_date
+			FROM trackpoint
+			WINDOW w AS (ORDER BY date_time, activity_id)
+		) sq
+		WHERE activity_id IS NOT NULL AND prev_act = activity_id
+		AND date_time - prev_date >= INTERVAL '5 minutes'
+	) invalid
+	GROUP BY user_id
+	ORDER BY user_id`
+
+	rows, err := u.db.QueryContext(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanInvalidActivities(rows)
+}
+
+// UsersAround returns the users with a trackpoint in the box of aroundBox
+// degrees around lat and lon. && narrows the trackpoints down with the GiST
+// index on the locations, and ST_Intersects checks the ones it finds.
+func (u *userStore) UsersAround(lat, lon float64) ([]string, error) {
+	query := `SELECT DISTINCT user_id FROM trackpoint
+		WHERE location && ST_MakeEnvelope($1::float8, $2::float8, $3::float8, $4::float8, 4326)::geography
+		AND ST_Intersects(location, ST_MakeEnvelope($1::float8, $2::float8, $3::float8, $4::float8, 4326)::geography)
+		ORDER BY user_id`
+
+	rows, err := u.db.QueryContext(context.TODO(), query, lon-aroundBox, lat-aroundBox, lon+aroundBox, lat+aroundBox)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanIDs(rows)
+}
+
+// UsersNear returns the users with a trackpoint at most meters away from lat and
+// lon. ST_DWithin uses the GiST index on the locations.
+func (u *userStore) UsersNear(lat, lon, meters float64) ([]string, error) {
+	query := `SELECT DISTINCT user_id FROM trackpoint
+		WHERE ST_DWithin(location, ST_SetSRID(ST_MakePoint($1::float8, $2::float8), 4326)::geography, $3::float8)
+		ORDER BY user_id`
+
+	rows, err := u.db.QueryContext(context.TODO(), query, lon, lat, meters)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanIDs(rows)
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+// ScanUserCounts reads rows of a user id and a number of activities and closes them.
+func ScanUserCounts(rows *sql.Rows) ([]activity.UserCount, error) {
+	var users []activity.UserCount
+	err := scanCounts(rows, func(name string, count int) {
+		users = append(users, activity.UserCount{UserID: name, Activities: count})
+	})
+	return users, err
+}
+
+// ScanModeCounts reads rows of a transportation mode and a number of activities
+// and closes them.
+func ScanModeCounts(rows *sql.Rows) ([]activity.ModeCount, error) {
+	var modes []activity.ModeCount
+	err := scanCounts(rows, func(name string, count int) {
+		modes = append(modes, activity.ModeCount{TransportationMode: name, Activities: count})
+	})
+	return modes, err
+}
+
+// ScanInvalidActivities reads rows of a user id and a number of invalid
+// activities and closes them.
+func ScanInvalidActivities(rows *sql.Rows) ([]user.UserWithInvalidActivities, error) {
+	var users []user.UserWithInvalidActivities
+	err := scanCounts(rows, func(name string, count int) {
+		users = append(users, user.UserWithInvalidActivities{UserID: name, InvalidActivities: count})
+	})
+	return users, err
+}
+
+// scanCounts reads rows of a name and a count, passes them to add and closes
+// the rows.
+func scanCounts(rows *sql.Rows, add func(name string, count int)) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return err
+		}
+		add(name, count)
+	}
+	return rows.Err()
+}
+
+// ScanYears reads rows of a year and a total and closes them.
+func ScanYears(rows *sql.Rows) ([]activity.YearTotal, error) {
+	defer rows.Close()
+
+	var years []activity.YearTotal
+	for rows.Next() {
+		var year activity.YearTotal
+		if err := rows.Scan(&year.Year, &year.Total); err != nil {
+			return nil, err
+		}
+		years = append(years, year)
+	}
+	return years, rows.Err()
+}
+
+// ScanTrackpoints reads rows of the id, activity_id, user_id, lat, lon,
+// altitude, altitude_raw, date_days and date_time of trackpoints and closes them.
+func ScanTrackpoints(rows *sql.Rows) ([]trackpoint.Trackpoint, error) {
+	defer rows.Close()
+
+	var trackpoints []trackpoint.Trackpoint
+	for rows.Next() {
+		var p trackpoint.Trackpoint
+		if err := rows.Scan(&p.ID, &p.ActivityID, &p.UserID, &p.Lat, &p.Lon, &p.Altitude, &p.AltitudeRaw, &p.DateDays, &p.DateTime); err != nil {
+			return nil, err
+		}
+		trackpoints = append(trackpoints, p)
+	}
+	return trackpoints, rows.Err()
+}
+
+// ScanIDs reads rows of a single id and closes them.
+func ScanIDs(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,327 @@
+package shard
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/store"
+)
+
+type activityStore struct {
+	store *Store
+}
+
+func (a *activityStore) GetActivitiesForUser(userID string) ([]activity.Activity, error) {
+	shard, err := a.store.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.Activities().GetActivitiesForUser(userID)
+}
+
+func (a *activityStore) DeleteForUser(userID string) error {
+	shard, err := a.store.user(userID)
+	if err != nil {
+		return err
+	}
+	return shard.Activities().DeleteForUser(userID)
+}
+
+func (a *activityStore) GetCount() (int, error) {
+	counts := make([]int, len(a.store.shards))
+	err := a.store.eachWithShadows(func(i int, shard store.Store, shadow map[string]bool) error {
+		count, err := shard.Activities().GetCount()
+		if err != nil {
+			return err
+		}
+		activities, err := shadowActivities(shard.Activities(), shadow)
+		counts[i] = count - len(activities)
+		return err
+	})
+	return sum(counts), err
+}
+
+// AverageActivitesPerUser averages over the users with at least one activity on
+// any shard.
+func (a *activityStore) AverageActivitesPerUser() (float64, error) {
+	users, err := a.GetUsersActivityCount(-1)
+	if err != nil || len(users) == 0 {
+		return 0, err
+	}
+	total := 0
+	for _, usr := range users {
+		total += usr.Activities
+	}
+	return float64(total) / float64(len(users)), nil
+}
+
+// GetUsersActivityCount takes the limit users with most activities of every
+// shard, which holds all activities of its users, and returns the limit users
+// with most activities among them.
+func (a *activityStore) GetUsersActivityCount(limit int) ([]activity.UserCount, error) {
+	counts, err := a.counts(func(activities store.ActivityStore, shadow map[string]bool) ([]keyCount, error) {
+		// ask for as many more users as may be left out
+		shardLimit := limit
+		if limit != -1 {
+			shardLimit += len(shadow)
+		}
+		users, err := activities.GetUsersActivityCount(shardLimit)
+		var kept []keyCount
+		for _, usr := range users {
+			if !shadow[usr.UserID] {
+				kept = append(kept, keyCount{key: usr.UserID, count: usr.Activities})
+			}
+		}
+		return kept, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if limit != -1 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+
+	users := make([]activity.UserCount, len(counts))
+	for i, c := range counts {
+		users[i] = activity.UserCount{UserID: c.key, Activities: c.count}
+	}
+	return users, nil
+}
+
+func (a *activityStore) GetTransportationCounts() ([]activity.ModeCount, error) {
+	counts, err := a.counts(func(activities store.ActivityStore, shadow map[string]bool) ([]keyCount, error) {
+		modes, err := activities.GetTransportationCounts()
+		if err != nil {
+			return nil, err
+		}
+		counts := make([]keyCount, len(modes))
+		for i, mode := range modes {
+			counts[i] = keyCount{key: mode.TransportationMode, count: mode.Activities}
+		}
+		if len(shadow) == 0 {
+			return counts, nil
+		}
+
+		left, err := shadowActivities(activities, shadow)
+		for _, act := range left {
+			for i := range counts {
+				if strings.EqualFold(counts[i].key, act.TransportationMode) {
+					counts[i].count--
+					break
+				}
+			}
+		}
+		return counts, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	modes := make([]activity.ModeCount, len(counts))
+	for i, c := range counts {
+		modes[i] = activity.ModeCount{TransportationMode: c.key, Activities: c.count}
+	}
+	return modes, nil
+}
+
+func (a *activityStore) YearWithMostActivites() (int, int, error) {
+	years, err := a.GetActivityCountsByYear()
+	if err != nil {
+		return 0, 0, err
+	}
+	year, count := maxByYear(years)
+	return year, count, nil
+}
+
+func (a *activityStore) YearWithMostHours() (int, int, error) {
+	years, err := a.GetHoursByYear()
+	if err != nil {
+		return 0, 0, err
+	}
+	year, hours := maxByYear(years)
+	return year, hours, nil
+}
+
+func (a *activityStore) GetActivityCountsByYear() ([]activity.YearTotal, error) {
+	return a.years(nil, func(act activity.Activity) int {
+		return 1
+	})
+}
+
+func (a *activityStore) GetHoursByYear() ([]activity.YearTotal, error) {
+	return a.years(store.ActivityStore.GetHoursByYear, func(act activity.Activity) int {
+		return int(act.EndDateTime.Sub(act.StartDateTime).Hours())
+	})
+}
+
+func (a *activityStore) GetDistanceByUser(userID, transportationMode string, year int) (float64, error) {
+	shard, err := a.store.user(userID)
+	if err != nil {
+		return 0, err
+	}
+	return shard.Activities().GetDistanceByUser(userID, transportationMode, year)
+}
+
+func (a *activityStore) GetTopTransportationByUsers() ([]activity.TopMode, error) {
+	shardTop := make([][]activity.TopMode, len(a.store.shards))
+	err := a.store.eachWithShadows(func(i int, shard store.Store, shadow map[string]bool) error {
+		top, err := shard.Activities().GetTopTransportationByUsers()
+		for _, mode := range top {
+			if !shadow[mode.UserID] {
+				shardTop[i] = append(shardTop[i], mode)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var top []activity.TopMode
+	for i := range shardTop {
+		top = append(top, shardTop[i]...)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		return top[i].UserID < top[j].UserID
+	})
+	return top, nil
+}
+
+// keyCount is a key with its count, which counts merges over the shards.
+type keyCount struct {
+	key   string
+	count int
+}
+
+// counts runs query on every shard and adds up the counts of keys that are
+// equal ignoring case, like the transportation modes are grouped on MySQL, under
+// the first spelling of the key. The keys are returned by count, highest first, and then by key, without the ones
+// that have no count left.
+func (a *activityStore) counts(query func(activities store.ActivityStore, shadow map[string]bool) ([]keyCount, error)) ([]keyCount, error) {
+	shardCounts := make([][]keyCount, len(a.store.shards))
+	err := a.store.eachWithShadows(func(i int, shard store.Store, shadow map[string]bool) error {
+		var err error
+		shardCounts[i], err = query(shard.Activities(), shadow)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	var counts []keyCount
+	for i := range shardCounts {
+		for _, c := range shardCounts[i] {
+			folded := strings.ToLower(c.key)
+			k, ok := index[folded]
+			if !ok {
+				k = len(counts)
+				index[folded] = k
+				counts = append(counts, keyCount{key: c.key})
+			}
+			counts[k].count += c.count
+		}
+	}
+
+	n := 0
+	for _, c := range counts {
+		if c.count > 0 {
+			counts[n] = c
+			n++
+		}
+	}
+	counts = counts[:n]
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].key < counts[j].key
+	})
+	return counts, nil
+}
+
+// years runs query on every shard and adds up the totals of equal years, less
+// the value of every activity of the users left out. Without a query the totals
+// are the activity counts. The years with activities are returned in order.
+func (a *activityStore) years(query func(activities store.ActivityStore) ([]activity.YearTotal, error), value func(act activity.Activity) int) ([]activity.YearTotal, error) {
+	counts := make([]map[int]int, len(a.store.shards))
+	totals := make([]map[int]int, len(a.store.shards))
+	err := a.store.eachWithShadows(func(i int, shard store.Store, shadow map[string]bool) error {
+		counts[i] = make(map[int]int)
+		totals[i] = make(map[int]int)
+		years, err := shard.Activities().GetActivityCountsByYear()
+		if err != nil {
+			return err
+		}
+		for _, y := range years {
+			counts[i][y.Year] += y.Total
+		}
+
+		if query != nil {
+			years, err = query(shard.Activities())
+			if err != nil {
+				return err
+			}
+		}
+		for _, y := range years {
+			totals[i][y.Year] += y.Total
+		}
+
+		left, err := shadowActivities(shard.Activities(), shadow)
+		for _, act := range left {
+			counts[i][act.StartDateTime.Year()]--
+			totals[i][act.StartDateTime.Year()] -= value(act)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	count := make(map[int]int)
+	total := make(map[int]int)
+	for i := range totals {
+		for year, n := range counts[i] {
+			count[year] += n
+		}
+		for year, v := range totals[i] {
+			total[year] += v
+		}
+	}
+	var years []activity.YearTotal
+	for year, n := range count {
+		if n > 0 {
+			years = append(years, activity.YearTotal{Year: year, Total: total[year]})
+		}
+	}
+	sort.Slice(years, func(i, j int) bool {
+		return years[i].Year < years[j].Year
+	})
+	return years, nil
+}
+
+// shadowActivities returns the activities of the users left out of a shard.
+func shadowActivities(activities store.ActivityStore, shadow map[string]bool) ([]activity.Activity, error) {
+	var left []activity.Activity
+	for userID := range shadow {
+		userActivities, err := activities.GetActivitiesForUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		left = append(left, userActivities...)
+	}
+	return left, nil
+}
+
+// maxByYear returns the year with the highest total, the earliest on ties.
+// years have to be in order.
+func maxByYear(years []activity.YearTotal) (int, int) {
+	year, max := 0, 0
+	for i, y := range years {
+		if i == 0 || y.Total > max {
+			year, max = y.Year, y.Total
+		}
+	}
+	return year, max
+}
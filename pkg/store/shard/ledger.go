@@ -0,0 +1,39 @@
+package shard
+
+import "github.com/spacycoder/db_mysql/pkg/ledger"
+
+type ledgerStore struct {
+	store *Store
+}
+
+func (l *ledgerStore) GetEntriesForUser(userID string) (map[string]ledger.Entry, error) {
+	shard, err := l.store.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.Ledger().GetEntriesForUser(userID)
+}
+
+func (l *ledgerStore) Skip(entry ledger.Entry) error {
+	shard, err := l.store.user(entry.UserID)
+	if err != nil {
+		return err
+	}
+	return shard.Ledger().Skip(entry)
+}
+
+func (l *ledgerStore) Fail(entry ledger.Entry, cause error) error {
+	shard, err := l.store.user(entry.UserID)
+	if err != nil {
+		return err
+	}
+	return shard.Ledger().Fail(entry, cause)
+}
+
+func (l *ledgerStore) DeleteForUser(userID string) error {
+	shard, err := l.store.user(userID)
+	if err != nil {
+		return err
+	}
+	return shard.Ledger().DeleteForUser(userID)
+}
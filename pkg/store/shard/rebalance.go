@@ -0,0 +1,309 @@
+package shard
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+type RebalanceOptions struct {
+	// BatchRows is the maximum number of trackpoints copied at once.
+	BatchRows int
+	// Planned is called with the routes of all users that move, if set.
+	Planned func(routes []Route)
+	// Moved is called after a user has moved with the number of activities and
+	// trackpoints it has, if set.
+	Moved func(route Route, activities, trackpoints int)
+}
+
+// Rebalance moves every user to the shard the ring assigns it to. It first puts
+// a route for every user that is on another shard into the routing table, so
+// that reads find the users that have not moved yet, and then moves one user at
+// a time: its user row, activities, trackpoints and ledger entries are copied to
+// the target, the copied rows are counted, and the rows are deleted from the
+// source. An interrupted rebalance is continued from the routing table.
+//
+// Nothing may be loaded while the shards are rebalanced.
+func (s *Store) Rebalance(opts RebalanceOptions) error {
+	if s.routes == nil {
+		return errors.New("shard: rebalancing needs a routing table")
+	}
+	if opts.BatchRows <= 0 {
+		return fmt.Errorf("shard: invalid batch size %d", opts.BatchRows)
+	}
+
+	routes, err := s.plan()
+	if err != nil {
+		return err
+	}
+	if opts.Planned != nil {
+		opts.Planned(routes)
+	}
+
+	for _, route := range routes {
+		activities, trackpoints, err := s.move(route, opts.BatchRows)
+		if err != nil {
+			return fmt.Errorf("moving user %s from %s to %s: %w", route.UserID, route.Source, route.Target, err)
+		}
+		if opts.Moved != nil {
+			opts.Moved(route, activities, trackpoints)
+		}
+	}
+	return nil
+}
+
+// plan returns the routes left by an interrupted rebalance and adds a route for
+// every other user that is not on the shard of the ring.
+func (s *Store) plan() ([]Route, error) {
+	routes, err := s.routes.Routes()
+	if err != nil {
+		return nil, err
+	}
+	routed := make(map[string]bool)
+	for _, route := range routes {
+		routed[route.UserID] = true
+	}
+
+	var planned []Route
+	for i, shard := range s.shards {
+		users, err := shard.Users().GetUsers()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.names[i], err)
+		}
+		for _, usr := range users {
+			target := s.Shard(usr.ID)
+			if target == i || routed[usr.ID] {
+				continue
+			}
+			planned = append(planned, Route{
+				UserID: usr.ID,
+				Source: s.names[i],
+				Target: s.names[target],
+				State:  RoutePending,
+			})
+			routed[usr.ID] = true
+		}
+	}
+
+	if len(planned) > 0 {
+		if err := s.routes.Put(planned...); err != nil {
+			return nil, err
+		}
+	}
+	return append(routes, planned...), nil
+}
+
+// move copies the rows of a user from the source to the target of route unless
+// they are copied already, and deletes them from the source.
+func (s *Store) move(route Route, batchRows int) (int, int, error) {
+	source, err := s.shardIndex(route.Source)
+	if err != nil {
+		return 0, 0, err
+	}
+	target, err := s.shardIndex(route.Target)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var activities, trackpoints int
+	if route.State != RouteCopied {
+		route.State = RouteCopying
+		if err := s.routes.Put(route); err != nil {
+			return 0, 0, err
+		}
+
+		activities, trackpoints, err = copyUser(s.shards[source], s.shards[target], route.UserID, batchRows)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		route.State = RouteCopied
+		if err := s.routes.Put(route); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err := deleteUser(s.shards[source], route.UserID); err != nil {
+		return 0, 0, err
+	}
+	return activities, trackpoints, s.routes.Delete(route.UserID)
+}
+
+// copyUser copies a user with its activities, trackpoints and finished ledger
+// entries from src to dst and checks that dst has as many rows of the user as
+// src. Rows of the user that dst has from an earlier attempt are deleted first.
+// The trackpoints and ledger entries are written in one transaction, so the
+// user only shows up on dst with all of them.
+func copyUser(src, dst store.Store, userID string, batchRows int) (int, int, error) {
+	usr, err := findUser(src, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := deleteRows(dst, userID); err != nil {
+		return 0, 0, err
+	}
+	if err := dst.Users().CreateUser(usr.ID, usr.HasLabels); err != nil {
+		return 0, 0, err
+	}
+
+	activities, err := src.Activities().GetActivitiesForUser(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = store.InTx(dst, func(tx store.Tx) error {
+		return tx.CreateActivities(activities)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the activities get new ids on dst, which the trackpoints have to refer to
+	copied, err := dst.Activities().GetActivitiesForUser(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(copied) != len(activities) {
+		return 0, 0, fmt.Errorf("copied %d of %d activities", len(copied), len(activities))
+	}
+	ids, err := activityIDs(activities, copied)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	entries, err := src.Ledger().GetEntriesForUser(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = store.InTx(dst, func(tx store.Tx) error {
+		after := 0
+		for {
+			trackpoints, err := src.Trackpoints().GetForUser(userID, after, batchRows)
+			if err != nil {
+				return err
+			}
+			if len(trackpoints) == 0 {
+				break
+			}
+			after = trackpoints[len(trackpoints)-1].ID
+
+			for i, p := range trackpoints {
+				if p.ActivityID == nil {
+					continue
+				}
+				id, ok := ids[*p.ActivityID]
+				if !ok {
+					return fmt.Errorf("trackpoint %d has unknown activity %d", p.ID, *p.ActivityID)
+				}
+				trackpoints[i].ActivityID = &id
+			}
+			if err := tx.InsertTrackpoints(trackpoints); err != nil {
+				return err
+			}
+		}
+
+		var done []ledger.Entry
+		for _, e := range entries {
+			if e.Status == ledger.DONE {
+				done = append(done, e)
+			}
+		}
+		return tx.Record(done...)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, e := range entries {
+		switch e.Status {
+		case ledger.SKIPPED:
+			if err := dst.Ledger().Skip(e); err != nil {
+				return 0, 0, err
+			}
+		case ledger.FAILED:
+			cause := errors.New(e.Error)
+			if err := dst.Ledger().Fail(e, cause); err != cause {
+				return 0, 0, err
+			}
+		}
+	}
+
+	srcCount, err := src.Trackpoints().GetCountForUser(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	dstCount, err := dst.Trackpoints().GetCountForUser(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if srcCount != dstCount {
+		return 0, 0, fmt.Errorf("copied %d of %d trackpoints", dstCount, srcCount)
+	}
+	return len(activities), dstCount, nil
+}
+
+func findUser(st store.Store, userID string) (user.User, error) {
+	users, err := st.Users().GetUsers()
+	if err != nil {
+		return user.User{}, err
+	}
+	for _, usr := range users {
+		if usr.ID == userID {
+			return usr, nil
+		}
+	}
+	return user.User{}, fmt.Errorf("user %s not found", userID)
+}
+
+// activityKey identifies an activity of a user on every shard.
+type activityKey struct {
+	start, end int64
+	mode       string
+}
+
+func keyOf(a activity.Activity) activityKey {
+	return activityKey{start: a.StartDateTime.UnixNano(), end: a.EndDateTime.UnixNano(), mode: a.TransportationMode}
+}
+
+// activityIDs maps the ids of the activities of a user on one shard to the ids
+// of their copies on another shard.
+func activityIDs(activities, copied []activity.Activity) (map[int]int, error) {
+	copies := make(map[activityKey][]int)
+	for _, a := range copied {
+		copies[keyOf(a)] = append(copies[keyOf(a)], a.ID)
+	}
+
+	ids := make(map[int]int)
+	for _, a := range activities {
+		key := keyOf(a)
+		if len(copies[key]) == 0 {
+			return nil, fmt.Errorf("activity %d was not copied", a.ID)
+		}
+		ids[a.ID] = copies[key][0]
+		copies[key] = copies[key][1:]
+	}
+	return ids, nil
+}
+
+// deleteRows deletes the trackpoints, activities and ledger entries of a user.
+func deleteRows(st store.Store, userID string) error {
+	if err := st.Trackpoints().DeleteForUser(userID); err != nil {
+		return err
+	}
+	if err := st.Activities().DeleteForUser(userID); err != nil {
+		return err
+	}
+	return st.Ledger().DeleteForUser(userID)
+}
+
+// deleteUser deletes a user with all of its rows.
+func deleteUser(st store.Store, userID string) error {
+	if err := deleteRows(st, userID); err != nil {
+		return err
+	}
+	return st.Users().DeleteUser(userID)
+}
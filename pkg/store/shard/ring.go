@@ -0,0 +1,62 @@
+package shard
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// replicas is the number of points every node has on the ring. More points
+// spread the users more evenly over the nodes.
+const replicas = 128
+
+// Ring is a consistent hash ring. A key belongs to the node of the first point
+// at or after its hash, so adding a node only moves the keys that the points of
+// the new node take over.
+type Ring struct {
+	names  []string
+	points []point
+}
+
+type point struct {
+	hash uint32
+	node int
+}
+
+// NewRing returns a ring of the nodes with the given names. Node i of the ring
+// is names[i].
+func NewRing(names []string) *Ring {
+	r := &Ring{names: names}
+	for node, name := range names {
+		for i := 0; i < replicas; i++ {
+			r.points = append(r.points, point{hash: hash(fmt.Sprintf("%s#%d", name, i)), node: node})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i].hash < r.points[j].hash
+	})
+	return r
+}
+
+// Node returns the node key belongs to.
+func (r *Ring) Node(key string) int {
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// Names returns the names of the nodes of the ring.
+func (r *Ring) Names() []string {
+	return r.names
+}
+
+func hash(key string) uint32 {
+	sum := md5.Sum([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
@@ -0,0 +1,105 @@
+package shard
+
+import (
+	"sort"
+	"sync"
+)
+
+// RouteState is how far a user has moved to its new shard.
+type RouteState string
+
+const (
+	// RoutePending users have not started moving and are read from the source.
+	RoutePending RouteState = "pending"
+	// RouteCopying users are being copied and are read from the source. The
+	// target may hold part of their rows.
+	RouteCopying RouteState = "copying"
+	// RouteCopied users have all rows on the target and are read from it while
+	// their rows are deleted from the source.
+	RouteCopied RouteState = "copied"
+)
+
+// Route is a user that moves from the shard named Source to the shard named
+// Target.
+type Route struct {
+	UserID string
+	Source string
+	Target string
+	State  RouteState
+}
+
+// Holder returns the name of the shard that holds all rows of the user.
+func (r Route) Holder() string {
+	if r.State == RouteCopied {
+		return r.Target
+	}
+	return r.Source
+}
+
+// Shadow returns the name of the shard whose rows of the user are incomplete
+// or a leftover, and have to be left out of queries.
+func (r Route) Shadow() string {
+	if r.State == RouteCopied {
+		return r.Source
+	}
+	return r.Target
+}
+
+// RoutingTable keeps the routes of the users that are moving between shards.
+// A user without a route is on the shard the ring assigns it to.
+type RoutingTable interface {
+	// Routes returns all routes by user id.
+	Routes() ([]Route, error)
+	Get(userID string) (Route, bool, error)
+	// Put adds routes or replaces the routes of the same users.
+	Put(routes ...Route) error
+	Delete(userID string) error
+}
+
+// NewRoutingTable returns a routing table in memory, for shards that are only
+// used by one process.
+func NewRoutingTable() RoutingTable {
+	return &memoryRoutes{routes: make(map[string]Route)}
+}
+
+type memoryRoutes struct {
+	mu     sync.RWMutex
+	routes map[string]Route
+}
+
+func (m *memoryRoutes) Routes() ([]Route, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	routes := make([]Route, 0, len(m.routes))
+	for _, route := range m.routes {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].UserID < routes[j].UserID
+	})
+	return routes, nil
+}
+
+func (m *memoryRoutes) Get(userID string) (Route, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	route, ok := m.routes[userID]
+	return route, ok, nil
+}
+
+func (m *memoryRoutes) Put(routes ...Route) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, route := range routes {
+		m.routes[route.UserID] = route
+	}
+	return nil
+}
+
+func (m *memoryRoutes) Delete(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.routes, userID)
+	return nil
+}
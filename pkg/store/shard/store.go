@@ -0,0 +1,446 @@
+// Package shard implements the store interfaces on top of several stores, the
+// shards. A consistent hash ring assigns every user to one shard, which holds
+// the user with its activities, trackpoints and ledger entries. Operations on
+// one user go to its shard, and aggregates are computed on every shard in
+// parallel and merged.
+//
+// Users that move to another shard after shards were added or removed have a
+// route in a routing table, which tells the shard that holds all of their rows
+// while they are copied. Rows of moving users on the other shard are left out of
+// every query, so reads stay correct during Rebalance.
+package shard
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/migrations"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+type Options struct {
+	// Names are the names of the shards, Name(i) for shard i by default. The
+	// ring depends on the names, so a shard has to keep its name.
+	Names []string
+	// Ring are the names of the shards users are assigned to, all shards by
+	// default. The other shards only hold users that still have to move.
+	Ring []string
+	// Routes are the routes of the users that move between shards. Without a
+	// routing table every user is on the shard of the ring.
+	Routes RoutingTable
+}
+
+// New returns a store that spreads the users over shards.
+func New(shards []store.Store, opts Options) (*Store, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("shard: no shards")
+	}
+
+	names := opts.Names
+	if names == nil {
+		names = make([]string, len(shards))
+		for i := range shards {
+			names[i] = Name(i)
+		}
+	}
+	if len(names) != len(shards) {
+		return nil, fmt.Errorf("shard: %d names for %d shards", len(names), len(shards))
+	}
+
+	index := make(map[string]int)
+	for i, name := range names {
+		if _, ok := index[name]; ok {
+			return nil, fmt.Errorf("shard: duplicate shard %s", name)
+		}
+		index[name] = i
+	}
+
+	ring := opts.Ring
+	if ring == nil {
+		ring = names
+	}
+	if len(ring) == 0 {
+		return nil, errors.New("shard: no shards on the ring")
+	}
+	nodes := make([]int, len(ring))
+	for i, name := range ring {
+		shard, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("shard: unknown shard %s on the ring", name)
+		}
+		nodes[i] = shard
+	}
+
+	return &Store{
+		shards: shards,
+		names:  names,
+		index:  index,
+		ring:   NewRing(ring),
+		nodes:  nodes,
+		routes: opts.Routes,
+	}, nil
+}
+
+// Name returns the default name of shard i.
+func Name(i int) string {
+	return fmt.Sprintf("shard-%d", i)
+}
+
+var (
+	_ store.Store  = (*Store)(nil)
+	_ store.Router = (*Store)(nil)
+)
+
+type Store struct {
+	shards []store.Store
+	names  []string
+	// index is the index of each shard by name
+	index map[string]int
+	ring  *Ring
+	// nodes are the shards of the nodes of the ring
+	nodes  []int
+	routes RoutingTable
+}
+
+// Shard returns the index of the shard the ring assigns userID to.
+func (s *Store) Shard(userID string) int {
+	return s.nodes[s.ring.Node(userID)]
+}
+
+func (s *Store) Users() store.UserStore {
+	return &userStore{store: s}
+}
+
+func (s *Store) Activities() store.ActivityStore {
+	return &activityStore{store: s}
+}
+
+func (s *Store) Trackpoints() store.TrackpointStore {
+	return &trackpointStore{store: s}
+}
+
+func (s *Store) Ledger() store.LedgerStore {
+	return &ledgerStore{store: s}
+}
+
+func (s *Store) Migrator() store.Migrator {
+	return &migrator{store: s}
+}
+
+func (s *Store) Prepare() error {
+	return s.each(func(_ int, shard store.Store) error {
+		return shard.Prepare()
+	})
+}
+
+// Begin starts a transaction that begins a transaction on a shard the first time
+// it writes a row of one of its users. Only a transaction that writes to a
+// single shard is atomic, which is why the loader batches per shard.
+func (s *Store) Begin() (store.Tx, error) {
+	return &tx{store: s, txs: make(map[int]store.Tx), owners: make(map[string]int)}, nil
+}
+
+func (s *Store) Close() error {
+	var first error
+	for _, shard := range s.shards {
+		if err := shard.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// owner returns the index of the shard that holds all rows of userID.
+func (s *Store) owner(userID string) (int, error) {
+	if s.routes == nil {
+		return s.Shard(userID), nil
+	}
+
+	route, ok, err := s.routes.Get(userID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return s.Shard(userID), nil
+	}
+	return s.shardIndex(route.Holder())
+}
+
+// user returns the shard that holds all rows of userID.
+func (s *Store) user(userID string) (store.Store, error) {
+	i, err := s.owner(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.shards[i], nil
+}
+
+func (s *Store) shardIndex(name string) (int, error) {
+	i, ok := s.index[name]
+	if !ok {
+		return 0, fmt.Errorf("shard: unknown shard %s", name)
+	}
+	return i, nil
+}
+
+// shadows returns for every shard the moving users whose rows on it have to be
+// left out of queries.
+func (s *Store) shadows() ([]map[string]bool, error) {
+	shadows := make([]map[string]bool, len(s.shards))
+	if s.routes == nil {
+		return shadows, nil
+	}
+
+	routes, err := s.routes.Routes()
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		i, ok := s.index[route.Shadow()]
+		if !ok {
+			continue
+		}
+		if shadows[i] == nil {
+			shadows[i] = make(map[string]bool)
+		}
+		shadows[i][route.UserID] = true
+	}
+	return shadows, nil
+}
+
+// each calls fn for every shard in parallel and returns the error of the first
+// shard that failed.
+func (s *Store) each(fn func(i int, shard store.Store) error) error {
+	errs := make([]error, len(s.shards))
+	var wg sync.WaitGroup
+	for i, shard := range s.shards {
+		wg.Add(1)
+		go func(i int, shard store.Store) {
+			defer wg.Done()
+			errs[i] = fn(i, shard)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.names[i], err)
+		}
+	}
+	return nil
+}
+
+// eachWithShadows is each with the moving users fn has to leave out of the
+// results of the shard.
+func (s *Store) eachWithShadows(fn func(i int, shard store.Store, shadow map[string]bool) error) error {
+	shadows, err := s.shadows()
+	if err != nil {
+		return err
+	}
+	return s.each(func(i int, shard store.Store) error {
+		return fn(i, shard, shadows[i])
+	})
+}
+
+type tx struct {
+	store *Store
+	txs   map[int]store.Tx
+	// order is the order in which the shard transactions were begun.
+	order []int
+	// owners caches the shard of every user the transaction has written.
+	owners map[string]int
+}
+
+// shard returns the transaction on the shard of userID and begins it if needed.
+func (t *tx) shard(userID string) (store.Tx, error) {
+	i, ok := t.owners[userID]
+	if !ok {
+		var err error
+		i, err = t.store.owner(userID)
+		if err != nil {
+			return nil, err
+		}
+		t.owners[userID] = i
+	}
+
+	if shardTx, ok := t.txs[i]; ok {
+		return shardTx, nil
+	}
+	shardTx, err := t.store.shards[i].Begin()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.store.names[i], err)
+	}
+	t.txs[i] = shardTx
+	t.order = append(t.order, i)
+	return shardTx, nil
+}
+
+func (t *tx) CreateActivities(activities []activity.Activity) error {
+	groups := make(map[store.Tx][]activity.Activity)
+	for _, a := range activities {
+		shardTx, err := t.shard(a.UserID)
+		if err != nil {
+			return err
+		}
+		groups[shardTx] = append(groups[shardTx], a)
+	}
+	for shardTx, group := range groups {
+		if err := shardTx.CreateActivities(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tx) InsertTrackpoints(trackpoints []trackpoint.Trackpoint) error {
+	groups := make(map[store.Tx][]trackpoint.Trackpoint)
+	for _, p := range trackpoints {
+		shardTx, err := t.shard(p.UserID)
+		if err != nil {
+			return err
+		}
+		groups[shardTx] = append(groups[shardTx], p)
+	}
+	for shardTx, group := range groups {
+		if err := shardTx.InsertTrackpoints(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tx) Record(entries ...ledger.Entry) error {
+	groups := make(map[store.Tx][]ledger.Entry)
+	for _, e := range entries {
+		shardTx, err := t.shard(e.UserID)
+		if err != nil {
+			return err
+		}
+		groups[shardTx] = append(groups[shardTx], e)
+	}
+	for shardTx, group := range groups {
+		if err := shardTx.Record(group...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit commits the shard transactions in the order they were begun. If one
+// fails, the remaining ones are rolled back, but the ones before it stay
+// committed.
+func (t *tx) Commit() error {
+	for n, i := range t.order {
+		if err := t.txs[i].Commit(); err != nil {
+			for _, j := range t.order[n+1:] {
+				t.txs[j].Rollback()
+			}
+			return fmt.Errorf("%s: %w", t.store.names[i], err)
+		}
+	}
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	var first error
+	for _, i := range t.order {
+		if err := t.txs[i].Rollback(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// migrator applies every migration to all shards.
+type migrator struct {
+	store *Store
+}
+
+func (m *migrator) Up() ([]migrations.Migration, error) {
+	applied := make([][]migrations.Migration, len(m.store.shards))
+	err := m.store.each(func(i int, shard store.Store) error {
+		var err error
+		applied[i], err = shard.Migrator().Up()
+		return err
+	})
+	return union(applied), err
+}
+
+// Down rolls back the latest migration of every shard.
+func (m *migrator) Down() (*migrations.Migration, error) {
+	rolledBack := make([]*migrations.Migration, len(m.store.shards))
+	err := m.store.each(func(i int, shard store.Store) error {
+		var err error
+		rolledBack[i], err = shard.Migrator().Down()
+		return err
+	})
+	for _, migration := range rolledBack {
+		if migration != nil {
+			return migration, err
+		}
+	}
+	return nil, err
+}
+
+func (m *migrator) Reset() ([]migrations.Migration, error) {
+	rolledBack := make([][]migrations.Migration, len(m.store.shards))
+	err := m.store.each(func(i int, shard store.Store) error {
+		var err error
+		rolledBack[i], err = shard.Migrator().Reset()
+		return err
+	})
+	return union(rolledBack), err
+}
+
+// Status reports a migration as applied if it is applied on every shard, at the
+// time it was applied on the last of them.
+func (m *migrator) Status() ([]migrations.Status, error) {
+	statuses := make([][]migrations.Status, len(m.store.shards))
+	err := m.store.each(func(i int, shard store.Store) error {
+		var err error
+		statuses[i], err = shard.Migrator().Status()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := statuses[0]
+	for _, shardStatuses := range statuses[1:] {
+		for j, status := range shardStatuses {
+			if !status.Applied {
+				merged[j].Applied = false
+			}
+			if status.AppliedAt.After(merged[j].AppliedAt) {
+				merged[j].AppliedAt = status.AppliedAt
+			}
+		}
+	}
+	for j := range merged {
+		if !merged[j].Applied {
+			merged[j].AppliedAt = time.Time{}
+		}
+	}
+	return merged, nil
+}
+
+// union returns the migrations of all shards once, in the order of the first
+// shard that has them.
+func union(shardMigrations [][]migrations.Migration) []migrations.Migration {
+	seen := make(map[int]bool)
+	var all []migrations.Migration
+	for _, list := range shardMigrations {
+		for _, migration := range list {
+			if !seen[migration.Version] {
+				seen[migration.Version] = true
+				all = append(all, migration)
+			}
+		}
+	}
+	return all
+}
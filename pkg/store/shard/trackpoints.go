@@ -0,0 +1,70 @@
+package shard
+
+import (
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+type trackpointStore struct {
+	store *Store
+}
+
+func (t *trackpointStore) GetCount() (int, error) {
+	counts := make([]int, len(t.store.shards))
+	err := t.store.eachWithShadows(func(i int, shard store.Store, shadow map[string]bool) error {
+		count, err := shard.Trackpoints().GetCount()
+		if err != nil {
+			return err
+		}
+		for userID := range shadow {
+			shadowCount, err := shard.Trackpoints().GetCountForUser(userID)
+			if err != nil {
+				return err
+			}
+			count -= shadowCount
+		}
+		counts[i] = count
+		return nil
+	})
+	return sum(counts), err
+}
+
+func (t *trackpointStore) GetCountForUser(userID string) (int, error) {
+	shard, err := t.store.user(userID)
+	if err != nil {
+		return 0, err
+	}
+	return shard.Trackpoints().GetCountForUser(userID)
+}
+
+func (t *trackpointStore) GetForUser(userID string, afterID, limit int) ([]trackpoint.Trackpoint, error) {
+	shard, err := t.store.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.Trackpoints().GetForUser(userID, afterID, limit)
+}
+
+func (t *trackpointStore) GetAround(userID string, lat, lon, span float64, limit int) ([]trackpoint.Trackpoint, error) {
+	shard, err := t.store.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.Trackpoints().GetAround(userID, lat, lon, span, limit)
+}
+
+func (t *trackpointStore) DeleteForUser(userID string) error {
+	shard, err := t.store.user(userID)
+	if err != nil {
+		return err
+	}
+	return shard.Trackpoints().DeleteForUser(userID)
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,168 @@
+package shard
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+type userStore struct {
+	store *Store
+}
+
+func (u *userStore) CreateUser(id string, hasLabels bool) error {
+	shard, err := u.store.user(id)
+	if err != nil {
+		return err
+	}
+	return shard.Users().CreateUser(id, hasLabels)
+}
+
+func (u *userStore) DeleteUser(id string) error {
+	shard, err := u.store.user(id)
+	if err != nil {
+		return err
+	}
+	return shard.Users().DeleteUser(id)
+}
+
+func (u *userStore) GetUsers() ([]user.User, error) {
+	shardUsers := make([][]user.User, len(u.store.shards))
+	err := u.store.eachWithShadows(func(i int, shard store.Store, shadow map[string]bool) error {
+		users, err := shard.Users().GetUsers()
+		for _, usr := range users {
+			if !shadow[usr.ID] {
+				shardUsers[i] = append(shardUsers[i], usr)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var users []user.User
+	for _, list := range shardUsers {
+		users = append(users, list...)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+// GetCount counts the users of GetUsers, since a moving user may be on two
+// shards.
+func (u *userStore) GetCount() (int, error) {
+	users, err := u.GetUsers()
+	return len(users), err
+}
+
+func (u *userStore) GetUsersThatHasUsedTransportationMode(transportationMode string) ([]string, error) {
+	return u.userIDs(func(users store.UserStore) ([]string, error) {
+		return users.GetUsersThatHasUsedTransportationMode(transportationMode)
+	})
+}
+
+// GetUsersWithMostAltitude takes the numUsers users with most altitude of every
+// shard and returns the numUsers with most altitude among them.
+func (u *userStore) GetUsersWithMostAltitude(numUsers int) ([]user.UserWithAltitude, error) {
+	shardUsers := make([][]user.UserWithAltitude, len(u.store.shards))
+	err := u.store.eachWithShadows(func(i int, shard store.Store, shadow map[string]bool) error {
+		// ask for as many more users as may be left out
+		users, err := shard.Users().GetUsersWithMostAltitude(numUsers + len(shadow))
+		for _, usr := range users {
+			if !shadow[usr.UserID] {
+				shardUsers[i] = append(shardUsers[i], usr)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var users []user.UserWithAltitude
+	for _, list := range shardUsers {
+		users = append(users, list...)
+	}
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].GainedAltitude > users[j].GainedAltitude
+	})
+	if len(users) > numUsers {
+		users = users[:numUsers]
+	}
+	return users, nil
+}
+
+// GetUsersWithInvalidActivites combines the invalid activities of every shard.
+// Each shard only walks the trackpoints of its own users, so trackpoints of
+// users on other shards no longer break up a gap.
+func (u *userStore) GetUsersWithInvalidActivites() ([]user.UserWithInvalidActivities, error) {
+	shardUsers := make([][]user.UserWithInvalidActivities, len(u.store.shards))
+	err := u.store.eachWithShadows(func(i int, shard store.Store, shadow map[string]bool) error {
+		users, err := shard.Users().GetUsersWithInvalidActivites()
+		for _, usr := range users {
+			if !shadow[usr.UserID] {
+				shardUsers[i] = append(shardUsers[i], usr)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := []user.UserWithInvalidActivities{}
+	for i := range shardUsers {
+		users = append(users, shardUsers[i]...)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].UserID < users[j].UserID
+	})
+	return users, nil
+}
+
+func (u *userStore) UsersAround(lat, lon float64) ([]string, error) {
+	return u.userIDs(func(users store.UserStore) ([]string, error) {
+		return users.UsersAround(lat, lon)
+	})
+}
+
+// UsersNear asks every shard for the users near lat and lon. It fails if the
+// shards cannot find users by position.
+func (u *userStore) UsersNear(lat, lon, meters float64) ([]string, error) {
+	return u.userIDs(func(users store.UserStore) ([]string, error) {
+		finder, ok := users.(store.NearFinder)
+		if !ok {
+			return nil, errors.New("shard: the shards cannot find users by position")
+		}
+		return finder.UsersNear(lat, lon, meters)
+	})
+}
+
+// userIDs returns the user ids query returns on every shard, in order.
+func (u *userStore) userIDs(query func(users store.UserStore) ([]string, error)) ([]string, error) {
+	shardUsers := make([][]string, len(u.store.shards))
+	err := u.store.eachWithShadows(func(i int, shard store.Store, shadow map[string]bool) error {
+		users, err := query(shard.Users())
+		for _, userID := range users {
+			if !shadow[userID] {
+				shardUsers[i] = append(shardUsers[i], userID)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := []string{}
+	for _, list := range shardUsers {
+		users = append(users, list...)
+	}
+	sort.Strings(users)
+	return users, nil
+}
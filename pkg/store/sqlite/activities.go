@@ -0,0 +1,168 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/store"
+)
+
+type activityStore struct {
+	db         *sql.DB
+	insertStmt *sql.Stmt
+}
+
+func (a *activityStore) loadStatements() error {
+	insertStmt, err := a.db.PrepareContext(context.TODO(), "INSERT INTO Activity(user_id, transportation_mode, start_date_time, end_date_time) VALUES( ?, ?, ?, ? )")
+	if err != nil {
+		return err
+	}
+	a.insertStmt = insertStmt
+	return nil
+}
+
+func (a *activityStore) GetActivitiesForUser(userID string) ([]activity.Activity, error) {
+	rows, err := a.db.QueryContext(context.TODO(), "SELECT id, user_id, transportation_mode, start_date_time, end_date_time FROM Activity WHERE user_id = ? ORDER BY start_date_time ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []activity.Activity
+	for rows.Next() {
+		var act activity.Activity
+		if err := rows.Scan(&act.ID, &act.UserID, &act.TransportationMode, &act.StartDateTime, &act.EndDateTime); err != nil {
+			return nil, err
+		}
+		activities = append(activities, act)
+	}
+	return activities, rows.Err()
+}
+
+func (a *activityStore) DeleteForUser(userID string) error {
+	_, err := a.db.ExecContext(context.TODO(), "DELETE FROM Activity WHERE user_id = ?", userID)
+	return err
+}
+
+func (a *activityStore) GetCount() (int, error) {
+	var count int
+	err := a.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM Activity").Scan(&count)
+	return count, err
+}
+
+func (a *activityStore) AverageActivitesPerUser() (float64, error) {
+	var avg sql.NullFloat64
+	err := a.db.QueryRowContext(context.TODO(), "SELECT AVG(count) FROM UserActivityCount").Scan(&avg)
+	return avg.Float64, err
+}
+
+func (a *activityStore) GetUsersActivityCount(limit int) ([]activity.UserCount, error) {
+	rows, err := a.db.QueryContext(context.TODO(), "SELECT user_id, COUNT(user_id) FROM Activity GROUP BY user_id ORDER BY 2 DESC, 1 LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanUserCounts(rows)
+}
+
+func (a *activityStore) GetTransportationCounts() ([]activity.ModeCount, error) {
+	rows, err := a.db.QueryContext(context.TODO(), "SELECT transportation_mode, COUNT(transportation_mode) FROM Activity GROUP BY transportation_mode ORDER BY 2 DESC, 1")
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanModeCounts(rows)
+}
+
+func (a *activityStore) YearWithMostActivites() (int, int, error) {
+	query := "SELECT CAST(strftime('%Y', start_date_time) AS INTEGER) AS year, COUNT(*) AS count FROM Activity GROUP BY year ORDER BY count DESC LIMIT 1"
+	var year, count int
+	err := a.db.QueryRowContext(context.TODO(), query).Scan(&year, &count)
+	return year, count, err
+}
+
+// YearWithMostHours truncates the duration of each activity to whole hours
+// before summing, like TIMESTAMPDIFF does in the MySQL query.
+func (a *activityStore) YearWithMostHours() (int, int, error) {
+	query := `SELECT CAST(strftime('%Y', start_date_time) AS INTEGER) AS year,
+		SUM((strftime('%s', end_date_time) - strftime('%s', start_date_time)) / 3600) AS duration
+		FROM Activity GROUP BY year ORDER BY duration DESC LIMIT 1`
+	var year, hours int
+	err := a.db.QueryRowContext(context.TODO(), query).Scan(&year, &hours)
+	return year, hours, err
+}
+
+func (a *activityStore) GetActivityCountsByYear() ([]activity.YearTotal, error) {
+	rows, err := a.db.QueryContext(context.TODO(), "SELECT CAST(strftime('%Y', start_date_time) AS INTEGER) AS year, COUNT(*) FROM Activity GROUP BY year ORDER BY year")
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanYears(rows)
+}
+
+func (a *activityStore) GetHoursByYear() ([]activity.YearTotal, error) {
+	rows, err := a.db.QueryContext(context.TODO(), `SELECT CAST(strftime('%Y', start_date_time) AS INTEGER) AS year,
+		SUM((strftime('%s', end_date_time) - strftime('%s', start_date_time)) / 3600)
+		FROM Activity GROUP BY year ORDER BY year`)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanYears(rows)
+}
+
+func (a *activityStore) GetDistanceByUser(userID, transportationMode string, year int) (float64, error) {
+	rows, err := a.db.QueryContext(context.TODO(), `SELECT a.id, t.lat, t.lon FROM Activity AS a
+		INNER JOIN Trackpoint AS t ON a.id = t.activity_id
+		AND a.user_id = ?
+		AND a.transportation_mode = ?
+		AND CAST(strftime('%Y', a.start_date_time) AS INTEGER) = ?
+		ORDER BY t.date_time`, userID, transportationMode, year)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	distance := 0.0
+	currentActivityID := -1
+	var prevLat, prevLon float64
+	for rows.Next() {
+		var activityID int
+		var lat, lon float64
+		if err := rows.Scan(&activityID, &lat, &lon); err != nil {
+			return 0, err
+		}
+		if activityID == currentActivityID {
+			distance += activity.Distance(prevLat, prevLon, lat, lon)
+		}
+		currentActivityID = activityID
+		prevLat = lat
+		prevLon = lon
+	}
+	return distance, rows.Err()
+}
+
+func (a *activityStore) GetTopTransportationByUsers() ([]activity.TopMode, error) {
+	rows, err := a.db.QueryContext(context.TODO(), `SELECT u.id, a.transportation_mode, COUNT(a.transportation_mode) AS ActivityCount
+		FROM User AS u INNER JOIN Activity AS a
+		ON u.id = a.user_id
+		GROUP BY u.id, a.transportation_mode
+		ORDER BY u.id, ActivityCount DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var top []activity.TopMode
+	var previousUser string
+	for rows.Next() {
+		var mode activity.TopMode
+		if err := rows.Scan(&mode.UserID, &mode.TransportationMode, &mode.Activities); err != nil {
+			return nil, err
+		}
+		if mode.UserID == previousUser {
+			continue
+		}
+		previousUser = mode.UserID
+		top = append(top, mode)
+	}
+	return top, rows.Err()
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+)
+
+type ledgerStore struct {
+	db         *sql.DB
+	upsertStmt *sql.Stmt
+}
+
+func (l *ledgerStore) loadStatements() error {
+	upsertStmt, err := l.db.PrepareContext(context.TODO(), `INSERT INTO IngestionLedger(path, user_id, kind, size, mod_time, checksum, row_count, status, error, size_policy, max_points, updated_at)
+		VALUES( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )
+		ON CONFLICT(path) DO UPDATE SET size = excluded.size, mod_time = excluded.mod_time, checksum = excluded.checksum,
+		row_count = excluded.row_count, status = excluded.status, error = excluded.error,
+		size_policy = excluded.size_policy, max_points = excluded.max_points, updated_at = excluded.updated_at`)
+	if err != nil {
+		return err
+	}
+	l.upsertStmt = upsertStmt
+	return nil
+}
+
+func (l *ledgerStore) GetEntriesForUser(userID string) (map[string]ledger.Entry, error) {
+	rows, err := l.db.QueryContext(context.TODO(), "SELECT path, user_id, kind, size, mod_time, checksum, row_count, status, error, size_policy, max_points FROM IngestionLedger WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make(map[string]ledger.Entry)
+	for rows.Next() {
+		var e ledger.Entry
+		var modTime int64
+		var errMsg sql.NullString
+		if err := rows.Scan(&e.Path, &e.UserID, &e.Kind, &e.Size, &modTime, &e.Checksum, &e.RowCount, &e.Status, &errMsg, &e.SizePolicy, &e.MaxPoints); err != nil {
+			return nil, err
+		}
+		e.ModTime = time.Unix(0, modTime)
+		e.Error = errMsg.String
+		entries[e.Path] = e
+	}
+	return entries, rows.Err()
+}
+
+func (l *ledgerStore) Skip(entry ledger.Entry) error {
+	entry.RowCount = 0
+	entry.Status = ledger.SKIPPED
+	entry.Error = ""
+	return upsertEntry(l.upsertStmt, entry)
+}
+
+func (l *ledgerStore) Fail(entry ledger.Entry, cause error) error {
+	entry.RowCount = 0
+	entry.Status = ledger.FAILED
+	entry.Error = cause.Error()
+	if err := upsertEntry(l.upsertStmt, entry); err != nil {
+		return err
+	}
+	return cause
+}
+
+func (l *ledgerStore) DeleteForUser(userID string) error {
+	_, err := l.db.ExecContext(context.TODO(), "DELETE FROM IngestionLedger WHERE user_id = ?", userID)
+	return err
+}
+
+func upsertEntry(stmt *sql.Stmt, e ledger.Entry) error {
+	var errMsg *string
+	if e.Error != "" {
+		errMsg = &e.Error
+	}
+	_, err := stmt.ExecContext(context.TODO(), e.Path, e.UserID, e.Kind, e.Size, e.ModTime.UnixNano(), e.Checksum, e.RowCount, e.Status, errMsg, e.SizePolicy, e.MaxPoints, time.Now())
+	return err
+}
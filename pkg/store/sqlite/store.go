@@ -0,0 +1,153 @@
+// Package sqlite implements the store interfaces on a single SQLite file, so the
+// dataset can be loaded and queried without a database server.
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/migrations"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open opens the SQLite database in the file dsn, which is created if it does
+// not exist. SQLite allows one writer at a time, so the store uses a single
+// connection and the loader's writers take turns instead of failing with
+// "database is locked".
+func Open(dsn string) (*Store, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	migrator, err := migrations.New(db, migrations.DialectSQLite, migrations.SQLite)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{
+		db:          db,
+		users:       &userStore{db: db},
+		activities:  &activityStore{db: db},
+		trackpoints: &trackpointStore{db: db},
+		ledger:      &ledgerStore{db: db},
+		migrator:    migrator,
+	}, nil
+}
+
+var _ store.Store = (*Store)(nil)
+
+type Store struct {
+	db          *sql.DB
+	users       *userStore
+	activities  *activityStore
+	trackpoints *trackpointStore
+	ledger      *ledgerStore
+	migrator    *migrations.Migrator
+}
+
+func (s *Store) Users() store.UserStore {
+	return s.users
+}
+
+func (s *Store) Activities() store.ActivityStore {
+	return s.activities
+}
+
+func (s *Store) Trackpoints() store.TrackpointStore {
+	return s.trackpoints
+}
+
+func (s *Store) Ledger() store.LedgerStore {
+	return s.ledger
+}
+
+func (s *Store) Migrator() store.Migrator {
+	return s.migrator
+}
+
+func (s *Store) Prepare() error {
+	if err := s.activities.loadStatements(); err != nil {
+		return err
+	}
+
+	if err := s.trackpoints.loadStatements(); err != nil {
+		return err
+	}
+
+	if err := s.users.loadStatements(); err != nil {
+		return err
+	}
+
+	return s.ledger.loadStatements()
+}
+
+func (s *Store) Begin() (store.Tx, error) {
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &tx{tx: sqlTx, store: s}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+type tx struct {
+	tx    *sql.Tx
+	store *Store
+}
+
+func (t *tx) CreateActivities(activities []activity.Activity) error {
+	stmt := t.tx.Stmt(t.store.activities.insertStmt)
+	for _, a := range activities {
+		if _, err := stmt.Exec(a.UserID, a.TransportationMode, a.StartDateTime, a.EndDateTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertTrackpoints inserts the trackpoints one by one with a prepared
+// statement, which is as fast as multi-row statements for an in-process database.
+func (t *tx) InsertTrackpoints(trackpoints []trackpoint.Trackpoint) error {
+	stmt := t.tx.Stmt(t.store.trackpoints.insertStmt)
+	for _, p := range trackpoints {
+		if _, err := stmt.Exec(p.ActivityID, p.UserID, p.Lat, p.Lon, p.Altitude, p.AltitudeRaw, p.DateDays, p.DateTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tx) Record(entries ...ledger.Entry) error {
+	stmt := t.tx.Stmt(t.store.ledger.upsertStmt)
+	for _, entry := range entries {
+		entry.Status = ledger.DONE
+		entry.Error = ""
+		if err := upsertEntry(stmt, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *tx) Rollback() error {
+	return t.tx.Rollback()
+}
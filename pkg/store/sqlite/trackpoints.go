@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+type trackpointStore struct {
+	db         *sql.DB
+	insertStmt *sql.Stmt
+}
+
+func (t *trackpointStore) loadStatements() error {
+	insertStmt, err := t.db.PrepareContext(context.TODO(), "INSERT INTO Trackpoint(activity_id, user_id, lat, lon, altitude, altitude_raw, date_days, date_time) VALUES( ?, ?, ?, ?, ?, ?, ?, ? )")
+	if err != nil {
+		return err
+	}
+	t.insertStmt = insertStmt
+	return nil
+}
+
+func (t *trackpointStore) GetCount() (int, error) {
+	var count int
+	err := t.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM Trackpoint").Scan(&count)
+	return count, err
+}
+
+func (t *trackpointStore) GetCountForUser(userID string) (int, error) {
+	var count int
+	err := t.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM Trackpoint WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+func (t *trackpointStore) GetForUser(userID string, afterID, limit int) ([]trackpoint.Trackpoint, error) {
+	rows, err := t.db.QueryContext(context.TODO(), `SELECT id, activity_id, user_id, lat, lon, altitude, altitude_raw, date_days, date_time
+		FROM Trackpoint WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?`, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanTrackpoints(rows)
+}
+
+func (t *trackpointStore) GetAround(userID string, lat, lon, span float64, limit int) ([]trackpoint.Trackpoint, error) {
+	rows, err := t.db.QueryContext(context.TODO(), `SELECT id, activity_id, user_id, lat, lon, altitude, altitude_raw, date_days, date_time
+		FROM Trackpoint WHERE user_id = ? AND ABS(lat-?)<=? AND ABS(lon-?)<=? ORDER BY id LIMIT ?`, userID, lat, span, lon, span, limit)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanTrackpoints(rows)
+}
+
+func (t *trackpointStore) DeleteForUser(userID string) error {
+	_, err := t.db.ExecContext(context.TODO(), "DELETE FROM Trackpoint WHERE user_id = ?", userID)
+	return err
+}
@@ -0,0 +1,154 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+// aroundBox is the distance in degrees around a position that UsersAround searches.
+const aroundBox = 0.001
+
+type userStore struct {
+	db         *sql.DB
+	insertStmt *sql.Stmt
+}
+
+func (u *userStore) loadStatements() error {
+	insertStmt, err := u.db.PrepareContext(context.TODO(), "INSERT INTO User(id, has_labels) VALUES( ?, ? ) ON CONFLICT(id) DO UPDATE SET has_labels = excluded.has_labels")
+	if err != nil {
+		return err
+	}
+	u.insertStmt = insertStmt
+	return nil
+}
+
+func (u *userStore) CreateUser(id string, hasLabels bool) error {
+	_, err := u.insertStmt.ExecContext(context.TODO(), id, hasLabels)
+	return err
+}
+
+func (u *userStore) DeleteUser(id string) error {
+	_, err := u.db.ExecContext(context.TODO(), "DELETE FROM User WHERE id = ?", id)
+	return err
+}
+
+func (u *userStore) GetUsers() ([]user.User, error) {
+	rows, err := u.db.QueryContext(context.TODO(), "SELECT id, has_labels FROM User ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []user.User
+	for rows.Next() {
+		var usr user.User
+		if err := rows.Scan(&usr.ID, &usr.HasLabels); err != nil {
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+	return users, rows.Err()
+}
+
+func (u *userStore) GetCount() (int, error) {
+	var count int
+	err := u.db.QueryRowContext(context.TODO(), "SELECT COUNT(*) FROM User").Scan(&count)
+	return count, err
+}
+
+func (u *userStore) GetUsersThatHasUsedTransportationMode(transportationMode string) ([]string, error) {
+	rows, err := u.db.QueryContext(context.TODO(), "SELECT DISTINCT user_id FROM Activity WHERE transportation_mode = ? ORDER BY user_id", transportationMode)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanIDs(rows)
+}
+
+// GetUsersWithMostAltitude sums the altitude gained between consecutive
+// trackpoints of the walks of every user in one pass over the trackpoints.
+func (u *userStore) GetUsersWithMostAltitude(numUsers int) ([]user.UserWithAltitude, error) {
+	users, err := u.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := u.db.QueryContext(context.TODO(), `SELECT a.user_id, t.activity_id, t.altitude FROM Trackpoint t
+		INNER JOIN Activity a ON t.activity_id = a.id AND a.transportation_mode = 'walk' AND t.altitude IS NOT NULL
+		ORDER BY a.user_id, t.date_time, t.activity_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gained := make(map[string]float64)
+	currentActivityID := -1
+	prevAltitude := 0.0
+	for rows.Next() {
+		var userID string
+		var activityID int
+		var altitude float64
+		if err := rows.Scan(&userID, &activityID, &altitude); err != nil {
+			return nil, err
+		}
+		if activityID == currentActivityID && altitude > prevAltitude {
+			gained[userID] += altitude - prevAltitude
+		}
+		currentActivityID = activityID
+		prevAltitude = altitude
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	usersWithAltitude := make([]user.UserWithAltitude, len(users))
+	for i, usr := range users {
+		usersWithAltitude[i] = user.UserWithAltitude{UserID: usr.ID, GainedAltitude: gained[usr.ID]}
+	}
+	sort.SliceStable(usersWithAltitude, func(i, j int) bool {
+		return usersWithAltitude[i].GainedAltitude > usersWithAltitude[j].GainedAltitude
+	})
+	if len(usersWithAltitude) > numUsers {
+		usersWithAltitude = usersWithAltitude[:numUsers]
+	}
+	return usersWithAltitude, nil
+}
+
+// GetUsersWithInvalidActivites counts the activities per user in which two
+// consecutive trackpoints are at least five minutes apart. It compares each
+// trackpoint with the previous one in date order with LAG, where the MySQL
+// query uses session variables.
+func (u *userStore) GetUsersWithInvalidActivites() ([]user.UserWithInvalidActivities, error) {
+	query := `SELECT user_id, COUNT(*) FROM (
+		SELECT DISTINCT user_id, activity_id FROM (
+			SELECT user_id, activity_id, date_time,
+			LAG(activity_id) OVER w AS prev_act,
+			LAG(date_time) OVER w AS prev_date
+			FROM Trackpoint
+			WINDOW w AS (ORDER BY date_time, activity_id)
+		) sq
+		WHERE activity_id IS NOT NULL AND prev_act = activity_id
+		AND ABS(strftime('%s', date_time) - strftime('%s', prev_date)) / 60 > 4
+	) invalid
+	GROUP BY user_id
+	ORDER BY user_id`
+
+	rows, err := u.db.QueryContext(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanInvalidActivities(rows)
+}
+
+// UsersAround returns the users with a trackpoint at most aroundBox degrees
+// from lat and lon.
+func (u *userStore) UsersAround(lat, lon float64) ([]string, error) {
+	rows, err := u.db.QueryContext(context.TODO(), "SELECT DISTINCT user_id FROM Trackpoint WHERE ABS(lat-?)<=? AND ABS(lon-?)<=? ORDER BY user_id", lat, aroundBox, lon, aroundBox)
+	if err != nil {
+		return nil, err
+	}
+	return store.ScanIDs(rows)
+}
@@ -0,0 +1,128 @@
+// Package store defines the storage interfaces the loader and the tasks are
+// written against. Each database engine implements them in a sub package.
+package store
+
+import (
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+	"github.com/spacycoder/db_mysql/pkg/migrations"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+// Store is one storage backend.
+type Store interface {
+	Users() UserStore
+	Activities() ActivityStore
+	Trackpoints() TrackpointStore
+	Ledger() LedgerStore
+	Migrator() Migrator
+	// Prepare readies the store for queries once the schema exists.
+	Prepare() error
+	// Begin starts a unit of work of the loader.
+	Begin() (Tx, error)
+	Close() error
+}
+
+// Router is implemented by stores that spread users over several databases.
+// Shard returns the database userID is stored in. A transaction of the loader
+// should only write the rows of users of one shard.
+type Router interface {
+	Shard(userID string) int
+}
+
+// NearFinder is implemented by user stores that find users by their distance to
+// a position, which needs a spatial index.
+type NearFinder interface {
+	// UsersNear returns the users with a trackpoint at most meters away from lat
+	// and lon, by id.
+	UsersNear(lat, lon, meters float64) ([]string, error)
+}
+
+type UserStore interface {
+	// CreateUser creates a user or updates it if it exists.
+	CreateUser(id string, hasLabels bool) error
+	// DeleteUser deletes a user, whose other rows have to be deleted first.
+	DeleteUser(id string) error
+	GetUsers() ([]user.User, error)
+	GetCount() (int, error)
+	GetUsersThatHasUsedTransportationMode(transportationMode string) ([]string, error)
+	GetUsersWithMostAltitude(numUsers int) ([]user.UserWithAltitude, error)
+	GetUsersWithInvalidActivites() ([]user.UserWithInvalidActivities, error)
+	// UsersAround returns the users with a trackpoint close to lat and lon, by id.
+	UsersAround(lat, lon float64) ([]string, error)
+}
+
+type ActivityStore interface {
+	GetActivitiesForUser(userID string) ([]activity.Activity, error)
+	DeleteForUser(userID string) error
+	GetCount() (int, error)
+	AverageActivitesPerUser() (float64, error)
+	// GetUsersActivityCount returns the users with most activities, all of them if limit is -1.
+	GetUsersActivityCount(limit int) ([]activity.UserCount, error)
+	GetTransportationCounts() ([]activity.ModeCount, error)
+	YearWithMostActivites() (int, int, error)
+	YearWithMostHours() (int, int, error)
+	// GetActivityCountsByYear returns the number of activities started in each year, by year.
+	GetActivityCountsByYear() ([]activity.YearTotal, error)
+	// GetHoursByYear returns the hours of the activities started in each year, by year.
+	GetHoursByYear() ([]activity.YearTotal, error)
+	// GetDistanceByUser returns the kilometers a user covered in the activities
+	// of a transportation mode started in a year.
+	GetDistanceByUser(userID, transportationMode string, year int) (float64, error)
+	GetTopTransportationByUsers() ([]activity.TopMode, error)
+}
+
+type TrackpointStore interface {
+	GetCount() (int, error)
+	GetCountForUser(userID string) (int, error)
+	// GetForUser returns at most limit trackpoints of a user with an id above
+	// afterID, by id.
+	GetForUser(userID string, afterID, limit int) ([]trackpoint.Trackpoint, error)
+	// GetAround returns at most limit trackpoints of a user whose latitude and
+	// longitude are both at most span degrees from lat and lon, by id.
+	GetAround(userID string, lat, lon, span float64, limit int) ([]trackpoint.Trackpoint, error)
+	DeleteForUser(userID string) error
+}
+
+// LedgerStore records which dataset files have been loaded.
+type LedgerStore interface {
+	GetEntriesForUser(userID string) (map[string]ledger.Entry, error)
+	Skip(entry ledger.Entry) error
+	// Fail records that loading the file failed with cause and returns cause.
+	Fail(entry ledger.Entry, cause error) error
+	DeleteForUser(userID string) error
+}
+
+// Tx is a unit of work of the loader. Its writes and ledger entries become
+// visible together on Commit, or not at all.
+type Tx interface {
+	CreateActivities(activities []activity.Activity) error
+	InsertTrackpoints(trackpoints []trackpoint.Trackpoint) error
+	// Record marks the entries as done. Their row counts have to be set.
+	Record(entries ...ledger.Entry) error
+	Commit() error
+	Rollback() error
+}
+
+// Migrator evolves the schema of a store. *migrations.Migrator implements it.
+type Migrator interface {
+	Up() ([]migrations.Migration, error)
+	Down() (*migrations.Migration, error)
+	Reset() ([]migrations.Migration, error)
+	Status() ([]migrations.Status, error)
+}
+
+// InTx runs fn in a transaction of s, which is committed if fn succeeds and
+// rolled back otherwise.
+func InTx(s Store, fn func(tx Tx) error) error {
+	tx, err := s.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -2,13 +2,20 @@ package trackpoint
 
 import "time"
 
+// RowBytes is an upper estimate of the size of one trackpoint in an INSERT
+// statement or a LOAD DATA stream.
+const RowBytes = 192
+
 type Trackpoint struct {
 	ID         int
 	UserID     string
 	ActivityID *int
 	Lat        float64
 	Lon        float64
-	Altitude   int
-	DateDays   float64
-	DateTime   time.Time
+	// Altitude is in meters, nil when the dataset has no valid altitude.
+	Altitude *float64
+	// AltitudeRaw is the altitude in feet as in the dataset, -777 when invalid.
+	AltitudeRaw float64
+	DateDays    float64
+	DateTime    time.Time
 }
@@ -4,3 +4,16 @@ type User struct {
 	ID        string
 	HasLabels bool
 }
+
+// UserWithAltitude is the altitude in meters a user has gained walking.
+type UserWithAltitude struct {
+	UserID         string  `json:"user_id"`
+	GainedAltitude float64 `json:"gained_altitude"`
+}
+
+// UserWithInvalidActivities is the number of activities of a user with a gap
+// of 5 minutes or more between two trackpoints.
+type UserWithInvalidActivities struct {
+	UserID            string `json:"user_id"`
+	InvalidActivities int    `json:"invalid_activities"`
+}
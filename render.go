@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spf13/pflag"
+)
+
+// Formats the results of the tasks can be rendered in.
+const (
+	formatTable    = "table"
+	formatJSON     = "json"
+	formatCSV      = "csv"
+	formatNDJSON   = "ndjson"
+	formatMarkdown = "markdown"
+)
+
+var formats = []string{formatTable, formatJSON, formatCSV, formatNDJSON, formatMarkdown}
+
+// taskResult is what a task returned, with the arguments it was run with. Rows
+// is a struct or a slice of structs, whose fields are the columns, named by
+// their json tags.
+type taskResult struct {
+	Task        int         `json:"task"`
+	Description string      `json:"description"`
+	Parameters  taskArgs    `json:"parameters"`
+	Rows        interface{} `json:"rows"`
+}
+
+// renderer writes the results of tasks in a format.
+type renderer interface {
+	// add writes the result of a task.
+	add(result taskResult) error
+	// close writes what comes after the last result.
+	close() error
+}
+
+// newRenderer returns the renderer of format that writes to w.
+func newRenderer(format string, w io.Writer) (renderer, error) {
+	switch format {
+	case formatTable:
+		return &tableRenderer{w: w}, nil
+	case formatJSON:
+		return &jsonRenderer{w: w}, nil
+	case formatCSV:
+		return &csvRenderer{w: w}, nil
+	case formatNDJSON:
+		return &ndjsonRenderer{w: w}, nil
+	case formatMarkdown:
+		return &markdownRenderer{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, use one of %s", format, strings.Join(formats, ", "))
+}
+
+// output is the format and the file of the --format and --out flags, and the
+// file of the HTML report of --report.
+type output struct {
+	format string
+	path   string
+	report string
+}
+
+func addOutputFlags(flags *pflag.FlagSet) *output {
+	o := &output{}
+	flags.StringVar(&o.format, "format", formatTable, "format of the results: "+strings.Join(formats, ", "))
+	flags.StringVar(&o.path, "out", "", "file to write the results to instead of standard output")
+	return o
+}
+
+// check checks the format, so that it fails before connecting.
+func (o *output) check() error {
+	_, err := newRenderer(o.format, ioutil.Discard)
+	return err
+}
+
+// render runs fn with a renderer that writes to the output, and to the HTML
+// report if there is one, and finishes them after it. The report reads what
+// its charts show from st.
+func (o *output) render(st store.Store, fn func(r renderer) error) (err error) {
+	var w io.Writer = os.Stdout
+	if o.path != "" {
+		var f *os.File
+		if f, err = os.Create(o.path); err != nil {
+			return err
+		}
+		defer closeFile(f, &err)
+		w = f
+	}
+
+	r, err := newRenderer(o.format, w)
+	if err != nil {
+		return err
+	}
+	if o.report != "" {
+		var f *os.File
+		if f, err = os.Create(o.report); err != nil {
+			return err
+		}
+		defer closeFile(f, &err)
+		r = multiRenderer{r, newHTMLReport(st, f)}
+	}
+
+	if err := fn(r); err != nil {
+		return err
+	}
+	return r.close()
+}
+
+// closeFile closes f, and sets *err to the error of Close unless it is set.
+func closeFile(f *os.File, err *error) {
+	if closeErr := f.Close(); *err == nil {
+		*err = closeErr
+	}
+}
+
+// multiRenderer adds every result to all of its renderers.
+type multiRenderer []renderer
+
+func (m multiRenderer) add(result taskResult) error {
+	for _, r := range m {
+		if err := r.add(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiRenderer) close() error {
+	for _, r := range m {
+		if err := r.close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resultRows returns the type of the rows of a task result and the rows.
+func resultRows(rows interface{}) (reflect.Type, []reflect.Value) {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		return v.Type(), []reflect.Value{v}
+	}
+
+	values := make([]reflect.Value, v.Len())
+	for i := range values {
+		values[i] = v.Index(i)
+	}
+	return v.Type().Elem(), values
+}
+
+// columns returns the names of the fields of a row type.
+func columns(t reflect.Type) []string {
+	names := make([]string, t.NumField())
+	for i := range names {
+		names[i] = strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+	}
+	return names
+}
+
+// cells formats the fields of a row, floats with precision decimals, or as few
+// as represent them exactly if precision is -1.
+func cells(row reflect.Value, precision int) []string {
+	values := make([]string, row.NumField())
+	for i := range values {
+		field := row.Field(i)
+		if field.Kind() == reflect.Float64 {
+			values[i] = strconv.FormatFloat(field.Float(), 'f', precision, 64)
+		} else {
+			values[i] = fmt.Sprint(field.Interface())
+		}
+	}
+	return values
+}
+
+// tableRenderer writes every result as a table under the number of its task.
+type tableRenderer struct {
+	w io.Writer
+}
+
+func (r *tableRenderer) add(result taskResult) error {
+	fmt.Fprintln(r.w, "------------------")
+	fmt.Fprintf(r.w, "      Task %d      \n", result.Task)
+	fmt.Fprintln(r.w, "------------------")
+
+	t, rows := resultRows(result.Rows)
+	table := tablewriter.NewWriter(r.w)
+	table.SetHeader(columns(t))
+	for _, row := range rows {
+		table.Append(cells(row, 2))
+	}
+	table.Render()
+	return nil
+}
+
+func (r *tableRenderer) close() error {
+	return nil
+}
+
+// jsonRenderer writes an array with an object for every result.
+type jsonRenderer struct {
+	w     io.Writer
+	count int
+}
+
+func (r *jsonRenderer) add(result taskResult) error {
+	_, rows := resultRows(result.Rows)
+	values := make([]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = row.Interface()
+	}
+	result.Rows = values
+
+	b, err := json.MarshalIndent(result, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if r.count == 0 {
+		sep = "[\n  "
+	}
+	r.count++
+	_, err = fmt.Fprint(r.w, sep, string(b))
+	return err
+}
+
+func (r *jsonRenderer) close() error {
+	if r.count == 0 {
+		_, err := fmt.Fprintln(r.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprintln(r.w, "\n]")
+	return err
+}
+
+// csvRenderer writes the rows of every result under a header of its columns,
+// with the number of the task in the first column and an empty line between
+// the results.
+type csvRenderer struct {
+	w     io.Writer
+	count int
+}
+
+func (r *csvRenderer) add(result taskResult) error {
+	if r.count > 0 {
+		if _, err := fmt.Fprintln(r.w); err != nil {
+			return err
+		}
+	}
+	r.count++
+
+	t, rows := resultRows(result.Rows)
+	w := csv.NewWriter(r.w)
+	w.Write(append([]string{"task"}, columns(t)...))
+	task := strconv.Itoa(result.Task)
+	for _, row := range rows {
+		w.Write(append([]string{task}, cells(row, -1)...))
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (r *csvRenderer) close() error {
+	return nil
+}
+
+// ndjsonRenderer writes every row of every result as an object on its own
+// line, with the number of the task in it.
+type ndjsonRenderer struct {
+	w io.Writer
+}
+
+func (r *ndjsonRenderer) add(result taskResult) error {
+	_, rows := resultRows(result.Rows)
+	for _, row := range rows {
+		b, err := json.Marshal(row.Interface())
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(r.w, "{\"task\":%d,%s\n", result.Task, b[1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ndjsonRenderer) close() error {
+	return nil
+}
+
+// markdownRenderer writes every result as a table under a heading with its task.
+type markdownRenderer struct {
+	w io.Writer
+}
+
+func (r *markdownRenderer) add(result taskResult) error {
+	t, rows := resultRows(result.Rows)
+	names := columns(t)
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Task %d: %s\n\n", result.Task, result.Description)
+	rule := make([]string, len(names))
+	for i := range rule {
+		rule[i] = "---"
+	}
+	writeMarkdownRow(&b, names)
+	writeMarkdownRow(&b, rule)
+	for _, row := range rows {
+		writeMarkdownRow(&b, cells(row, 2))
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(r.w, b.String())
+	return err
+}
+
+func (r *markdownRenderer) close() error {
+	return nil
+}
+
+// writeMarkdownRow writes the cells of a row of a Markdown table.
+func writeMarkdownRow(b *strings.Builder, values []string) {
+	b.WriteString("|")
+	for _, v := range values {
+		b.WriteString(" " + strings.ReplaceAll(v, "|", "\\|") + " |")
+	}
+	b.WriteString("\n")
+}
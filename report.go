@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spacycoder/db_mysql/pkg/ledger"
+)
+
+// FileFailure is a user or file that could not be loaded.
+type FileFailure struct {
+	User  string `json:"user"`
+	File  string `json:"file,omitempty"`
+	Error string `json:"error"`
+}
+
+func newFailure(file ledger.Entry, err error) FileFailure {
+	return FileFailure{User: file.UserID, File: file.Path, Error: err.Error()}
+}
+
+// FileMatch counts how the trackpoints of one file were matched to labels.
+type FileMatch struct {
+	User      string `json:"user"`
+	File      string `json:"file"`
+	Matched   int    `json:"matched"`
+	Unmatched int    `json:"unmatched"`
+	Ambiguous int    `json:"ambiguous"`
+}
+
+// count records points trackpoints that were each covered by the given number of labels.
+func (m *FileMatch) count(labels, points int) {
+	switch {
+	case labels == 0:
+		m.Unmatched += points
+	case labels == 1:
+		m.Matched += points
+	default:
+		m.Ambiguous += points
+	}
+}
+
+// LimitedFile is a trajectory with more trackpoints than the configured maximum.
+// Points is -1 for skipped files, since they are not read to the end.
+type LimitedFile struct {
+	User   string `json:"user"`
+	File   string `json:"file"`
+	Policy string `json:"policy"`
+	Points int    `json:"points"`
+	Kept   int    `json:"kept"`
+}
+
+// LoadReport summarizes a dataset load. It is safe for concurrent use.
+type LoadReport struct {
+	mu sync.Mutex
+
+	Started     time.Time     `json:"started"`
+	Duration    time.Duration `json:"duration_ns"`
+	Users       int           `json:"users"`
+	Trackpoints int           `json:"trackpoints"`
+	Strategy    string        `json:"strategy"`
+	MatchMode   string        `json:"match_mode"`
+	Failures    []FileFailure `json:"failures"`
+	Matches     []FileMatch   `json:"matches"`
+	Limited     []LimitedFile `json:"size_limited"`
+}
+
+func (r *LoadReport) AddFailure(f FileFailure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Failures = append(r.Failures, f)
+}
+
+// AddMatch records the label matching of a file of a user with labels.
+func (r *LoadReport) AddMatch(m FileMatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Matches = append(r.Matches, m)
+}
+
+// AddLimited records a file that was skipped or truncated because of its size.
+func (r *LoadReport) AddLimited(l LimitedFile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Limited = append(r.Limited, l)
+}
+
+// AddTrackpoints counts n inserted trackpoints.
+func (r *LoadReport) AddTrackpoints(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Trackpoints += n
+}
+
+// Throughput returns the inserted trackpoints per second.
+func (r *LoadReport) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Trackpoints) / r.Duration.Seconds()
+}
+
+func (r *LoadReport) Print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fmt.Fprintf(w, "Loaded %d users in %s\n", r.Users, r.Duration)
+	fmt.Fprintf(w, "Inserted %d trackpoints with the %s strategy: %.0f rows/s\n", r.Trackpoints, r.Strategy, r.Throughput())
+
+	var total FileMatch
+	for _, m := range r.Matches {
+		total.Matched += m.Matched
+		total.Unmatched += m.Unmatched
+		total.Ambiguous += m.Ambiguous
+	}
+	fmt.Fprintf(w, "Trackpoints of labeled users (%s matching): %d matched, %d unmatched, %d ambiguous\n", r.MatchMode, total.Matched, total.Unmatched, total.Ambiguous)
+
+	r.printLimited(w)
+
+	if len(r.Failures) == 0 {
+		fmt.Fprintln(w, "No failures")
+		return
+	}
+
+	fmt.Fprintf(w, "%d failures:\n", len(r.Failures))
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"User ID", "File", "Error"})
+	for _, f := range r.Failures {
+		table.Append([]string{f.User, f.File, f.Error})
+	}
+	table.Render()
+}
+
+func (r *LoadReport) WriteJSON(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+func (r *LoadReport) printLimited(w io.Writer) {
+	if len(r.Limited) == 0 {
+		return
+	}
+
+	counts := make(map[string]int)
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"User ID", "File", "Policy", "Points", "Kept"})
+	for _, l := range r.Limited {
+		counts[l.Policy]++
+		points := strconv.Itoa(l.Points)
+		if l.Points < 0 {
+			points = "-"
+		}
+		table.Append([]string{l.User, l.File, l.Policy, points, strconv.Itoa(l.Kept)})
+	}
+
+	fmt.Fprintf(w, "Trajectories over the size limit: %d skipped, %d truncated\n", counts[policySkip], counts[policyTruncate])
+	table.Render()
+}
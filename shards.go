@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spacycoder/db_mysql/pkg/store/mysql"
+	"github.com/spacycoder/db_mysql/pkg/store/shard"
+)
+
+// shardName matches the name in front of the DSN of a shard.
+var shardName = regexp.MustCompile(`^([A-Za-z0-9_-]+)=`)
+
+// parseShards returns the names and DSNs of a list of shards. A shard without a
+// name is named after its position in the list.
+func parseShards(items []string) ([]string, []string) {
+	names := make([]string, len(items))
+	dataSources := make([]string, len(items))
+	for i, item := range items {
+		names[i] = shard.Name(i)
+		dataSources[i] = item
+		if m := shardName.FindStringSubmatch(item); m != nil {
+			names[i] = m[1]
+			dataSources[i] = item[len(m[0]):]
+		}
+	}
+	return names, dataSources
+}
+
+// openShards opens a MySQL store for each of config.Shards and config.OldShards
+// and returns a store that spreads the users over config.Shards. The routing
+// table of users that move between shards is kept on the shard named
+// config.Routes, shard-0 by default, so that it does not move when the shards
+// are listed in another order. It fails if another shard has routes, which
+// would be ignored.
+func openShards(config *Config) (*shard.Store, error) {
+	names, dataSources := parseShards(config.Shards)
+	oldNames, oldDataSources := parseShards(config.OldShards)
+	ring := append([]string(nil), names...)
+
+	known := make(map[string]string)
+	for i, name := range names {
+		known[name] = dataSources[i]
+	}
+	for i, name := range oldNames {
+		dataSource, ok := known[name]
+		if !ok {
+			names = append(names, name)
+			dataSources = append(dataSources, oldDataSources[i])
+			known[name] = oldDataSources[i]
+		} else if dataSource != oldDataSources[i] {
+			return nil, fmt.Errorf("shard %s has different DSNs in -shards and -old-shards", name)
+		}
+	}
+
+	routes := config.Routes
+	if routes == "" {
+		routes = shard.Name(0)
+	}
+	if _, ok := known[routes]; !ok {
+		return nil, fmt.Errorf("no shard is named %s to keep the routing table, set --routes to one of %s", routes, strings.Join(names, ", "))
+	}
+
+	var shards []store.Store
+	var stores []*mysql.Store
+	closeAll := func() {
+		for _, st := range shards {
+			st.Close()
+		}
+	}
+
+	for i, dataSource := range dataSources {
+		db, err := connect(config, "mysql", dataSource)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("%s: %w", names[i], err)
+		}
+		st, err := mysql.New(db, mysql.Options{Strategy: config.Strategy})
+		if err != nil {
+			db.Close()
+			closeAll()
+			return nil, err
+		}
+		shards = append(shards, st)
+		stores = append(stores, st)
+	}
+
+	var table *mysql.RoutingTable
+	for i, st := range stores {
+		if names[i] == routes {
+			table = st.RoutingTable()
+			continue
+		}
+		count, err := st.RoutingTable().Len()
+		if err == nil && count > 0 {
+			err = fmt.Errorf("%d routes in its ShardRoute table, but the routing table is kept on %s (see --routes)", count, routes)
+		}
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("%s: %w", names[i], err)
+		}
+	}
+
+	st, err := shard.New(shards, shard.Options{Names: names, Ring: ring, Routes: table})
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	return st, nil
+}
+
+// rebalance moves the users of st to the shards of config.Shards.
+func rebalance(config *Config, st *shard.Store) error {
+	start := time.Now()
+	total, moved := 0, 0
+	err := st.Rebalance(shard.RebalanceOptions{
+		BatchRows: config.BatchRows,
+		Planned: func(routes []shard.Route) {
+			total = len(routes)
+			fmt.Printf("Moving %d users\n", total)
+		},
+		Moved: func(route shard.Route, activities, trackpoints int) {
+			moved++
+			fmt.Printf("%d/%d moved user %s from %s to %s: %d activities, %d trackpoints\n",
+				moved, total, route.UserID, route.Source, route.Target, activities, trackpoints)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Moved %d users in %s\n", moved, time.Since(start).Round(time.Millisecond))
+	return nil
+}
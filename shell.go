@@ -0,0 +1,484 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spacycoder/db_mysql/pkg/store"
+	"github.com/spf13/pflag"
+)
+
+// timeLayout is how the shell prints times.
+const timeLayout = "2006-01-02 15:04:05"
+
+// errUsage is returned by a shell command called with the wrong arguments.
+var errUsage = errors.New("wrong arguments")
+
+// shellCommand is a command of the shell. run gets the words after the name.
+type shellCommand struct {
+	name        string
+	usage       string
+	description string
+	run         func(s *shell, args []string) error
+}
+
+var shellCommands = []shellCommand{
+	{"user", "user <id>", "show a user with its number of activities and trackpoints", (*shell).user},
+	{"activities", "activities <user id> [--mode <mode>] [--year <year>]", "list the activities of a user", (*shell).activities},
+	{"distance", "distance <user id> <mode> <year>", "kilometers a user covered with a transportation mode in a year", (*shell).distance},
+	{"near", "near <lat> <lon> <radius>", "users with a trackpoint within the radius, in m (the default) or km", (*shell).near},
+	{"task", "task <number> [--<parameter> <value>]...", "run a task of the assignment", (*shell).task},
+	{"tasks", "tasks", "list the tasks with their parameters", (*shell).tasks},
+	{"connect", "connect [flags]", "connect to another database, connect --help lists the flags", (*shell).connect},
+}
+
+// shell is an interactive prompt for questions about the loaded dataset. The
+// user ids, transportation modes and years it completes are read from the
+// database when it connects.
+type shell struct {
+	config  *Config
+	st      store.Store
+	userIDs []prompt.Suggest
+	modes   []prompt.Suggest
+	years   []prompt.Suggest
+	exit    bool
+}
+
+// runShell connects to the database of config and reads commands until exit,
+// quit or Ctrl-D.
+func runShell(config *Config) error {
+	s := &shell{config: config}
+	if err := s.open(config); err != nil {
+		return err
+	}
+	defer func() { s.st.Close() }()
+
+	fmt.Println("Type help for the commands, exit to leave")
+	p := prompt.New(s.execute, s.complete,
+		prompt.OptionTitle("strava"),
+		prompt.OptionLivePrefix(func() (string, bool) { return s.config.Driver + "> ", true }),
+		prompt.OptionSetExitCheckerOnInput(func(in string, breakline bool) bool { return breakline && s.exit }),
+	)
+	p.Run()
+	return nil
+}
+
+// open connects to the database of config, replacing the current one, and
+// reads the suggestions from it.
+func (s *shell) open(config *Config) error {
+	st, err := openStore(config)
+	if err != nil {
+		return err
+	}
+	if err := st.Prepare(); err != nil {
+		st.Close()
+		return err
+	}
+	if s.st != nil {
+		s.st.Close()
+	}
+	s.config = config
+	s.st = st
+	fmt.Println("Successfully connected to database")
+
+	if err := s.loadSuggestions(); err != nil {
+		fmt.Printf("Could not read the suggestions: %v\n", err)
+	}
+	return nil
+}
+
+func (s *shell) loadSuggestions() error {
+	users, err := s.st.Users().GetUsers()
+	if err != nil {
+		return err
+	}
+	s.userIDs = make([]prompt.Suggest, len(users))
+	for i, u := range users {
+		s.userIDs[i] = prompt.Suggest{Text: u.ID}
+	}
+
+	modes, err := s.st.Activities().GetTransportationCounts()
+	if err != nil {
+		return err
+	}
+	s.modes = nil
+	for _, mode := range modes {
+		if mode.TransportationMode != "" {
+			s.modes = append(s.modes, prompt.Suggest{Text: mode.TransportationMode, Description: strconv.Itoa(mode.Activities) + " activities"})
+		}
+	}
+
+	years, err := s.st.Activities().GetActivityCountsByYear()
+	if err != nil {
+		return err
+	}
+	s.years = make([]prompt.Suggest, len(years))
+	for i, year := range years {
+		s.years[i] = prompt.Suggest{Text: strconv.Itoa(year.Year), Description: strconv.Itoa(year.Total) + " activities"}
+	}
+	return nil
+}
+
+func (s *shell) execute(line string) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return
+	}
+
+	switch words[0] {
+	case "exit", "quit":
+		s.exit = true
+		return
+	case "help":
+		s.help()
+		return
+	}
+
+	for _, c := range shellCommands {
+		if c.name == words[0] {
+			err := c.run(s, words[1:])
+			if err == errUsage {
+				fmt.Printf("Usage: %s\n", c.usage)
+			} else if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return
+		}
+	}
+	fmt.Printf("Unknown command %s, type help for the commands\n", words[0])
+}
+
+func (s *shell) help() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Command", "Description"})
+	table.SetAutoWrapText(false)
+	for _, c := range shellCommands {
+		table.Append([]string{c.usage, c.description})
+	}
+	table.Append([]string{"help", "show this list"})
+	table.Append([]string{"exit", "leave the shell"})
+	table.Render()
+}
+
+func (s *shell) user(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	users, err := s.st.Users().GetUsers()
+	if err != nil {
+		return err
+	}
+	found := false
+	hasLabels := false
+	for _, u := range users {
+		if u.ID == args[0] {
+			found = true
+			hasLabels = u.HasLabels
+		}
+	}
+	if !found {
+		return fmt.Errorf("no user %s", args[0])
+	}
+
+	activities, err := s.st.Activities().GetActivitiesForUser(args[0])
+	if err != nil {
+		return err
+	}
+	trackpoints, err := s.st.Trackpoints().GetCountForUser(args[0])
+	if err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"User ID", "Has labels", "Activities", "Trackpoints"})
+	table.Append([]string{args[0], strconv.FormatBool(hasLabels), strconv.Itoa(len(activities)), strconv.Itoa(trackpoints)})
+	table.Render()
+	return nil
+}
+
+func (s *shell) activities(args []string) error {
+	var mode string
+	var year int
+	flags := newShellFlags("activities")
+	flags.StringVar(&mode, "mode", "", "only the activities of this transportation mode")
+	flags.IntVar(&year, "year", 0, "only the activities started in this year")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	activities, err := s.st.Activities().GetActivitiesForUser(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Mode", "Start", "End", "Duration"})
+	shown := 0
+	for _, act := range activities {
+		if mode != "" && !strings.EqualFold(act.TransportationMode, mode) {
+			continue
+		}
+		if year != 0 && act.StartDateTime.Year() != year {
+			continue
+		}
+		table.Append([]string{
+			strconv.Itoa(act.ID),
+			act.TransportationMode,
+			act.StartDateTime.Format(timeLayout),
+			act.EndDateTime.Format(timeLayout),
+			act.EndDateTime.Sub(act.StartDateTime).String(),
+		})
+		shown++
+	}
+	table.SetFooter([]string{"", "", "", "Activities", strconv.Itoa(shown)})
+	table.Render()
+	return nil
+}
+
+func (s *shell) distance(args []string) error {
+	if len(args) != 3 {
+		return errUsage
+	}
+	year, err := strconv.Atoi(args[2])
+	if err != nil {
+		return fmt.Errorf("invalid year: %s", args[2])
+	}
+
+	distance, err := s.st.Activities().GetDistanceByUser(args[0], args[1], year)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Distance: %f km\n", distance)
+	return nil
+}
+
+func (s *shell) near(args []string) error {
+	if len(args) != 3 {
+		return errUsage
+	}
+	lat, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return fmt.Errorf("invalid latitude: %s", args[0])
+	}
+	lon, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return fmt.Errorf("invalid longitude: %s", args[1])
+	}
+	meters, err := parseMeters(args[2])
+	if err != nil {
+		return err
+	}
+
+	finder, ok := s.st.Users().(store.NearFinder)
+	if !ok {
+		return fmt.Errorf("--driver %s cannot find users by position", s.config.Driver)
+	}
+	users, err := finder.UsersNear(lat, lon, meters)
+	if err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"User ID"})
+	for _, u := range users {
+		table.Append([]string{u})
+	}
+	table.Render()
+	return nil
+}
+
+// parseMeters parses a distance in meters, with an optional m or km unit.
+func parseMeters(s string) (float64, error) {
+	number, scale := s, 1.0
+	if strings.HasSuffix(s, "km") {
+		number, scale = strings.TrimSuffix(s, "km"), 1000
+	} else if strings.HasSuffix(s, "m") {
+		number = strings.TrimSuffix(s, "m")
+	}
+
+	meters, err := strconv.ParseFloat(number, 64)
+	if err != nil || meters < 0 {
+		return 0, fmt.Errorf("invalid radius: %s", s)
+	}
+	return meters * scale, nil
+}
+
+func (s *shell) task(args []string) error {
+	flags := newShellFlags("task")
+	values := addTaskFlags(flags)
+	out := addOutputFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+	n, err := strconv.Atoi(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid task number: %s", flags.Arg(0))
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+	return out.render(s.st, func(r renderer) error {
+		return runExercises(s.st, []int{n}, values(), r)
+	})
+}
+
+func (s *shell) tasks(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	listExercises()
+	return nil
+}
+
+// connect opens the database the flags describe, starting from the current
+// connection without its shards and replicas. The password is not a flag, so
+// that it stays out of the history of the prompt: it is the one read from
+// STRAVA_PASSWORD or the config file. The current connection is kept if it
+// fails.
+func (s *shell) connect(args []string) error {
+	next := *s.config
+	flags := newShellFlags("connect")
+	flags.StringVar(&next.Driver, "driver", next.Driver, "database to use: mysql, sqlite, postgres, mongo, memory")
+	flags.StringVar(&next.DSN, "dsn", next.DSN, "data source name, see the --dsn flag of the command line")
+	flags.StringVar(&next.Host, "host", next.Host, "host of the MySQL server")
+	flags.IntVar(&next.Port, "port", next.Port, "port of the MySQL server")
+	flags.StringVar(&next.User, "db-user", next.User, "user of the MySQL server")
+	flags.StringVar(&next.Database, "database", next.Database, "database on the MySQL server")
+	if err := flags.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			fmt.Print(flags.FlagUsages())
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() > 0 {
+		return errUsage
+	}
+
+	if flags.NFlag() > 0 {
+		next.Shards, next.OldShards, next.Replicas = nil, nil, nil
+		if !flags.Changed("dsn") {
+			next.DSN = ""
+		}
+	}
+	if err := validateConnection(&next); err != nil {
+		return err
+	}
+	return s.open(&next)
+}
+
+func (s *shell) complete(d prompt.Document) []prompt.Suggest {
+	before := d.TextBeforeCursor()
+	words := strings.Fields(before)
+	if before == "" || strings.HasSuffix(before, " ") {
+		words = append(words, "")
+	}
+	if len(words) == 1 {
+		var commands []prompt.Suggest
+		for _, c := range shellCommands {
+			commands = append(commands, prompt.Suggest{Text: c.name, Description: c.description})
+		}
+		commands = append(commands, prompt.Suggest{Text: "help"}, prompt.Suggest{Text: "exit"})
+		return prompt.FilterHasPrefix(commands, words[0], true)
+	}
+
+	current := words[len(words)-1]
+	return prompt.FilterHasPrefix(s.suggestions(words[0], words[1:len(words)-1]), current, true)
+}
+
+// suggestions returns what can follow the complete words after command.
+func (s *shell) suggestions(command string, words []string) []prompt.Suggest {
+	previous := ""
+	if len(words) > 0 {
+		previous = words[len(words)-1]
+	}
+	var positional []string
+	for i, word := range words {
+		if !strings.HasPrefix(word, "-") && (i == 0 || !strings.HasPrefix(words[i-1], "--") || strings.Contains(words[i-1], "=")) {
+			positional = append(positional, word)
+		}
+	}
+
+	switch command {
+	case "user":
+		if len(words) == 0 {
+			return s.userIDs
+		}
+	case "activities":
+		switch {
+		case previous == "--mode":
+			return s.modes
+		case previous == "--year":
+			return s.years
+		case len(positional) == 0:
+			return s.userIDs
+		default:
+			return []prompt.Suggest{{Text: "--mode"}, {Text: "--year"}}
+		}
+	case "distance":
+		switch len(words) {
+		case 0:
+			return s.userIDs
+		case 1:
+			return s.modes
+		case 2:
+			return s.years
+		}
+	case "task":
+		switch previous {
+		case "--user":
+			return s.userIDs
+		case "--mode":
+			return s.modes
+		case "--year":
+			return s.years
+		}
+		if len(positional) == 0 {
+			tasks := make([]prompt.Suggest, len(exercises))
+			for i, e := range exercises {
+				tasks[i] = prompt.Suggest{Text: strconv.Itoa(i + 1), Description: e.description}
+			}
+			return tasks
+		}
+		if n, err := strconv.Atoi(positional[0]); err == nil && n >= 1 && n <= len(exercises) {
+			var params []prompt.Suggest
+			for _, param := range exercises[n-1].params {
+				params = append(params, prompt.Suggest{Text: "--" + param.name, Description: param.usage + ", default " + param.value})
+			}
+			return params
+		}
+	case "connect":
+		if previous == "--driver" {
+			return []prompt.Suggest{{Text: driverMySQL}, {Text: driverSQLite}, {Text: driverPostgres}, {Text: driverMongo}, {Text: driverMemory}}
+		}
+		if !strings.HasPrefix(previous, "--") || strings.Contains(previous, "=") {
+			var flags []prompt.Suggest
+			for _, name := range []string{"driver", "dsn", "host", "port", "db-user", "database"} {
+				flags = append(flags, prompt.Suggest{Text: "--" + name})
+			}
+			return flags
+		}
+	}
+	return nil
+}
+
+// newShellFlags returns a flag set for the arguments of a shell command, which
+// reports errors instead of printing them.
+func newShellFlags(name string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	return flags
+}
@@ -1,207 +1,133 @@
 package main
 
 import (
-	"fmt"
-	"os"
-	"strconv"
-
-	"github.com/olekukonko/tablewriter"
 	"github.com/spacycoder/db_mysql/pkg/activity"
-	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+	"github.com/spacycoder/db_mysql/pkg/store"
 	"github.com/spacycoder/db_mysql/pkg/user"
 )
 
-func task1(activityService *activity.Service, userService *user.Service, trackpointService *trackpoint.Service) error {
+// tableCount is the number of rows of a table.
+type tableCount struct {
+	Table string `json:"table"`
+	Count int    `json:"count"`
+}
+
+// averageActivities is the average number of activities per user.
+type averageActivities struct {
+	ActivitiesPerUser float64 `json:"activities_per_user"`
+}
+
+// userRow is a user that a task found.
+type userRow struct {
+	UserID string `json:"user_id"`
+}
+
+// yearSummary compares the year with the most activities with the year with
+// the most hours of activities.
+type yearSummary struct {
+	YearWithMostActivities int  `json:"year_with_most_activities"`
+	Activities             int  `json:"activities"`
+	YearWithMostHours      int  `json:"year_with_most_hours"`
+	Hours                  int  `json:"hours"`
+	SameYear               bool `json:"same_year"`
+}
+
+// userDistance is the distance a user covered with a transportation mode in a
+// year.
+type userDistance struct {
+	UserID             string  `json:"user_id"`
+	TransportationMode string  `json:"transportation_mode"`
+	Year               int     `json:"year"`
+	Kilometers         float64 `json:"kilometers"`
+}
+
+func task1(activityService store.ActivityStore, userService store.UserStore, trackpointService store.TrackpointStore) ([]tableCount, error) {
 	usersCount, err := userService.GetCount()
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	activityCount, err := activityService.GetCount()
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	trackpointCount, err := trackpointService.GetCount()
 	if err != nil {
-		return nil
-	}
-
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Table", "Count"})
-	table.Append([]string{
-		"User", strconv.Itoa(usersCount),
-	})
-	table.Append([]string{
-		"Actvitity", strconv.Itoa(activityCount),
-	})
-	table.Append([]string{
-		"Trackpoint", strconv.Itoa(trackpointCount),
-	})
-	table.Render()
-	return nil
-}
-
-func task2(activityService *activity.Service) error {
-	res, err := activityService.AverageActivitesPerUser()
-	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Printf("Average number of activities per user is: %f\n", res)
-	return nil
+	return []tableCount{
+		{Table: "User", Count: usersCount},
+		{Table: "Activity", Count: activityCount},
+		{Table: "Trackpoint", Count: trackpointCount},
+	}, nil
 }
 
-func task3(activityService *activity.Service) error {
-	userIDs, counts, err := activityService.GetUsersActivityCount(20)
-	if err != nil {
-		return err
-	}
-
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"User Id", "Count"})
-	for i, userID := range userIDs {
-		table.Append([]string{
-			userID,
-			strconv.Itoa(counts[i]),
-		})
-	}
-	table.Render()
-	return nil
+func task2(activityService store.ActivityStore) (averageActivities, error) {
+	res, err := activityService.AverageActivitesPerUser()
+	return averageActivities{ActivitiesPerUser: res}, err
 }
 
-func task4(userService *user.Service) error {
-	users, err := userService.GetUsersThatHasUsedTransportationMode("Taxi")
-	if err != nil {
-		return err
-	}
-
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"User ID"})
-	for _, u := range users {
-		table.Append([]string{
-			u,
-		})
-	}
-	table.Render()
-	return nil
+func task3(activityService store.ActivityStore, limit int) ([]activity.UserCount, error) {
+	return activityService.GetUsersActivityCount(limit)
 }
 
-func task5(activityService *activity.Service) error {
-	transModes, transCounts, err := activityService.GetTransportationCounts()
-	if err != nil {
-		return err
-	}
+func task4(userService store.UserStore, transportationMode string) ([]userRow, error) {
+	users, err := userService.GetUsersThatHasUsedTransportationMode(transportationMode)
+	return userRows(users), err
+}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Transportation", "Count"})
-	for i, trans := range transModes {
-		table.Append([]string{
-			trans,
-			strconv.Itoa(transCounts[i]),
-		})
-	}
-	table.Render()
-	return nil
+func task5(activityService store.ActivityStore) ([]activity.ModeCount, error) {
+	return activityService.GetTransportationCounts()
 }
 
-func task6(activityService *activity.Service) error {
+func task6(activityService store.ActivityStore) (yearSummary, error) {
 	year, count, err := activityService.YearWithMostActivites()
 	if err != nil {
-		return err
+		return yearSummary{}, err
 	}
 
-	fmt.Printf("The year %d had %d activities which was the most of any year.\n", year, count)
-
-	yearWithMostHours, _, err := activityService.YearWithMostHours()
+	yearWithMostHours, hours, err := activityService.YearWithMostHours()
 	if err != nil {
-		return err
+		return yearSummary{}, err
 	}
 
-	if year == yearWithMostHours {
-		fmt.Println("The year with most hours was also the year with most activities.")
-	} else {
-		fmt.Println("The year with most activities was not the year with most hours.")
-	}
-	return nil
+	return yearSummary{
+		YearWithMostActivities: year,
+		Activities:             count,
+		YearWithMostHours:      yearWithMostHours,
+		Hours:                  hours,
+		SameYear:               year == yearWithMostHours,
+	}, nil
 }
 
-func task7(activityService *activity.Service) error {
-	distance, err := activityService.GetDistanceWalkedByUser("112")
-	if err != nil {
-		return err
-	}
-
-	fmt.Printf("Distance: %f\n\n", distance)
-	return nil
+func task7(activityService store.ActivityStore, userID, transportationMode string, year int) (userDistance, error) {
+	distance, err := activityService.GetDistanceByUser(userID, transportationMode, year)
+	return userDistance{UserID: userID, TransportationMode: transportationMode, Year: year, Kilometers: distance}, err
 }
 
-func task8(userService *user.Service) error {
-	users, err := userService.GetUsersWithMostAltitude(20)
-	if err != nil {
-		return err
-	}
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"User ID", "Altitude"})
-	for _, u := range users {
-		table.Append([]string{
-			u.UserID,
-			strconv.Itoa(u.GainedAltitude) + "m",
-		})
-	}
-	table.Render()
-	return nil
+func task8(userService store.UserStore, limit int) ([]user.UserWithAltitude, error) {
+	return userService.GetUsersWithMostAltitude(limit)
 }
 
-func task9(userService *user.Service) error {
-	users, counts, err := userService.GetUsersWithInvalidActivites()
-	if err != nil {
-		return err
-	}
-
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"User ID", "Invalid activities"})
-	for i, u := range users {
-		table.Append([]string{
-			u,
-			strconv.Itoa(counts[i]),
-		})
-	}
-
-	table.Render()
-	return nil
+func task9(userService store.UserStore) ([]user.UserWithInvalidActivities, error) {
+	return userService.GetUsersWithInvalidActivites()
 }
 
-func task10(userService *user.Service) error {
-	users, err := userService.UsersInBeijing()
-	if err != nil {
-		return err
-	}
-
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"User ID"})
-	for _, u := range users {
-		table.Append([]string{
-			u,
-		})
-	}
-
-	table.Render()
-	return nil
+func task10(userService store.UserStore, lat, lon float64) ([]userRow, error) {
+	users, err := userService.UsersAround(lat, lon)
+	return userRows(users), err
 }
 
-func task11(activityService *activity.Service) error {
-	activities, counts, err := activityService.GetTopTransportationByUsers()
-	if err != nil {
-		return err
-	}
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"User ID", "Mode", "Count"})
+func task11(activityService store.ActivityStore) ([]activity.TopMode, error) {
+	return activityService.GetTopTransportationByUsers()
+}
 
-	for i, a := range activities {
-		table.Append([]string{a.UserID, a.TransportationMode, strconv.Itoa(counts[i])})
+func userRows(userIDs []string) []userRow {
+	rows := make([]userRow, len(userIDs))
+	for i, userID := range userIDs {
+		rows[i] = userRow{UserID: userID}
 	}
-
-	table.Render()
-	return nil
+	return rows
 }
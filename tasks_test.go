@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/geolife"
+	"github.com/spacycoder/db_mysql/pkg/user"
+)
+
+// resultCollector is a renderer that keeps the results.
+type resultCollector struct {
+	results []taskResult
+}
+
+func (c *resultCollector) add(result taskResult) error {
+	c.results = append(c.results, result)
+	return nil
+}
+
+func (c *resultCollector) close() error {
+	return nil
+}
+
+// walkDistance is the length in kilometers of the walk of 010 in 2008.
+func walkDistance() float64 {
+	distance := 0.0
+	for i := 1; i < 6; i++ {
+		distance += activity.Distance(39.9+float64(i-1)*0.01, 116.39+float64(i-1)*0.001, 39.9+float64(i)*0.01, 116.39+float64(i)*0.001)
+	}
+	return distance
+}
+
+func TestExercises(t *testing.T) {
+	st := loadTestDataset(t, testConfig(t, testDataset))
+	values := map[string]string{"user": "010", "mode": "walk", "year": "2008", "lat": "39.9", "lon": "116.39"}
+
+	c := &resultCollector{}
+	if err := runExercises(st, nil, values, c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.results) != len(exercises) {
+		t.Fatalf("%d results, want %d", len(c.results), len(exercises))
+	}
+
+	want := map[int]interface{}{
+		1:  []tableCount{{Table: "User", Count: 3}, {Table: "Activity", Count: 6}, {Table: "Trackpoint", Count: 31}},
+		2:  averageActivities{ActivitiesPerUser: 3},
+		3:  []activity.UserCount{{UserID: "010", Activities: 3}, {UserID: "011", Activities: 3}},
+		4:  []userRow{{UserID: "010"}, {UserID: "011"}},
+		6:  yearSummary{YearWithMostActivities: 2008, Activities: 5, YearWithMostHours: 2008, Hours: 2, SameYear: true},
+		9:  []user.UserWithInvalidActivities{{UserID: "011", InvalidActivities: 1}},
+		10: []userRow{{UserID: "010"}, {UserID: "012"}},
+		11: []activity.TopMode{{UserID: "010", TransportationMode: "walk", Activities: 2}, {UserID: "011", TransportationMode: "bike", Activities: 2}},
+	}
+	for _, result := range c.results {
+		if rows, ok := want[result.Task]; ok && !reflect.DeepEqual(result.Rows, rows) {
+			t.Errorf("task %d = %+v, want %+v", result.Task, result.Rows, rows)
+		}
+	}
+
+	// the modes are counted ignoring case, under any of their spellings
+	modes := c.results[4].Rows.([]activity.ModeCount)
+	wantModes := []activity.ModeCount{{TransportationMode: "walk", Activities: 3}, {TransportationMode: "bike", Activities: 2}, {TransportationMode: "bus", Activities: 1}}
+	if len(modes) != len(wantModes) {
+		t.Fatalf("task 5 = %+v, want %+v", modes, wantModes)
+	}
+	for i, mode := range modes {
+		if !strings.EqualFold(mode.TransportationMode, wantModes[i].TransportationMode) || mode.Activities != wantModes[i].Activities {
+			t.Errorf("task 5 = %+v, want %+v", modes, wantModes)
+		}
+	}
+
+	distance := c.results[6].Rows.(userDistance)
+	if distance.UserID != "010" || distance.Year != 2008 || math.Abs(distance.Kilometers-walkDistance()) > 1e-9 {
+		t.Errorf("task 7 = %+v, want %.6f km", distance, walkDistance())
+	}
+
+	// 010 gained 35 feet on its walk of 2008 and 15 on the one of 2009, 011
+	// 10 feet, and 012 has no walks
+	altitudes := c.results[7].Rows.([]user.UserWithAltitude)
+	wantAltitudes := []user.UserWithAltitude{{UserID: "010", GainedAltitude: 50 * geolife.FeetToMeters}, {UserID: "011", GainedAltitude: 10 * geolife.FeetToMeters}, {UserID: "012"}}
+	if len(altitudes) != len(wantAltitudes) {
+		t.Fatalf("task 8 = %+v, want %+v", altitudes, wantAltitudes)
+	}
+	for i, alt := range altitudes {
+		if alt.UserID != wantAltitudes[i].UserID || math.Abs(alt.GainedAltitude-wantAltitudes[i].GainedAltitude) > 1e-9 {
+			t.Errorf("task 8 = %+v, want %+v", altitudes, wantAltitudes)
+		}
+	}
+}
+
+func TestExerciseParameters(t *testing.T) {
+	st := loadTestDataset(t, testConfig(t, testDataset))
+
+	tests := []struct {
+		tasks  []int
+		values map[string]string
+		want   []interface{}
+	}{
+		{[]int{3}, map[string]string{"limit": "1"}, []interface{}{[]activity.UserCount{{UserID: "010", Activities: 3}}}},
+		{[]int{4}, map[string]string{"mode": "BIKE"}, []interface{}{[]userRow{{UserID: "011"}}}},
+		{[]int{4, 10}, map[string]string{"mode": "bus", "lat": "40.0", "lon": "116.5"}, []interface{}{[]userRow{{UserID: "010"}}, []userRow{{UserID: "011"}}}},
+		{[]int{7}, map[string]string{"user": "011", "mode": "walk", "year": "2009"}, []interface{}{userDistance{UserID: "011", TransportationMode: "walk", Year: 2009}}},
+	}
+	for _, test := range tests {
+		c := &resultCollector{}
+		if err := runExercises(st, test.tasks, test.values, c); err != nil {
+			t.Fatalf("tasks %v: %v", test.tasks, err)
+		}
+		for i, result := range c.results {
+			if !reflect.DeepEqual(result.Rows, test.want[i]) {
+				t.Errorf("task %d with %v = %+v, want %+v", result.Task, test.values, result.Rows, test.want[i])
+			}
+		}
+	}
+
+	if err := runExercises(st, []int{12}, nil, &resultCollector{}); err == nil {
+		t.Error("task 12 ran, want an error")
+	}
+	if err := runExercises(st, []int{3}, map[string]string{"mode": "walk"}, &resultCollector{}); err == nil {
+		t.Error("task 3 ran with --mode, want an error")
+	}
+}
@@ -0,0 +1,12 @@
+Geolife trajectory
+WGS 84
+Altitude is in Feet
+Reserved 3
+0,2,255,My Track,0,0,2,8421376
+0
+39.9,116.39,0,100,39749.5000000000,2008-10-28,12:00:00
+39.91,116.391,0,110,39749.5006944444,2008-10-28,12:01:00
+39.92,116.392,0,105,39749.5013888889,2008-10-28,12:02:00
+39.93,116.393,0,120,39749.5020833333,2008-10-28,12:03:00
+39.94,116.394,0,130,39749.5027777778,2008-10-28,12:04:00
+39.95,116.395,0,125,39749.5034722222,2008-10-28,12:05:00
@@ -0,0 +1,10 @@
+Geolife trajectory
+WGS 84
+Altitude is in Feet
+Reserved 3
+0,2,255,My Track,0,0,2,8421376
+0
+39.98,116.31,0,150,39750.3750000000,2008-10-29,09:00:00
+39.98,116.312,0,150,39750.3756944444,2008-10-29,09:01:00
+39.98,116.314,0,150,39750.3763888889,2008-10-29,09:02:00
+39.98,116.316,0,150,39750.3770833333,2008-10-29,09:03:00
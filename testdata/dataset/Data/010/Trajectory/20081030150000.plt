@@ -0,0 +1,8 @@
+Geolife trajectory
+WGS 84
+Altitude is in Feet
+Reserved 3
+0,2,255,My Track,0,0,2,8421376
+0
+39.95,116.4,0,-777,39751.6250000000,2008-10-30,15:00:00
+39.95,116.4,0,-777,39751.6256944444,2008-10-30,15:01:00
@@ -0,0 +1,10 @@
+Geolife trajectory
+WGS 84
+Altitude is in Feet
+Reserved 3
+0,2,255,My Track,0,0,2,8421376
+0
+39.97,116.35,0,200,39873.3333333333,2009-03-01,08:00:00
+39.97,116.351,0,210,39873.3340277778,2009-03-01,08:01:00
+39.97,116.358,0,190,39873.3388888889,2009-03-01,08:08:00
+39.97,116.359,0,195,39873.3395833333,2009-03-01,08:09:00
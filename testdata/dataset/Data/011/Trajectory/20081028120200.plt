@@ -0,0 +1,13 @@
+Geolife trajectory
+WGS 84
+Altitude is in Feet
+Reserved 3
+0,2,255,My Track,0,0,2,8421376
+0
+40.0,116.5,0,50,39749.5013888889,2008-10-28,12:02:00
+40.0,116.502,0,50,39749.5020833333,2008-10-28,12:03:00
+40.0,116.504,0,50,39749.5027777778,2008-10-28,12:04:00
+40.0,116.506,0,50,39749.5034722222,2008-10-28,12:05:00
+40.0,116.508,0,50,39749.5041666667,2008-10-28,12:06:00
+40.0,116.51,0,50,39749.5048611111,2008-10-28,12:07:00
+40.0,116.512,0,50,39749.5055555556,2008-10-28,12:08:00
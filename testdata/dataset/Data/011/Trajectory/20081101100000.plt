@@ -0,0 +1,9 @@
+Geolife trajectory
+WGS 84
+Altitude is in Feet
+Reserved 3
+0,2,255,My Track,0,0,2,8421376
+0
+40.01,116.5,0,50,39753.4166666667,2008-11-01,10:00:00
+40.01,116.502,0,50,39753.4173611111,2008-11-01,10:01:00
+40.01,116.504,0,50,39753.4180555556,2008-11-01,10:02:00
@@ -0,0 +1,8 @@
+Geolife trajectory
+WGS 84
+Altitude is in Feet
+Reserved 3
+0,2,255,My Track,0,0,2,8421376
+0
+40.02,116.5,0,300,39754.4166666667,2008-11-02,10:00:00
+40.02,116.506,0,310,39754.4208333333,2008-11-02,10:06:00
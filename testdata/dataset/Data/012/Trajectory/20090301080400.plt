@@ -0,0 +1,9 @@
+Geolife trajectory
+WGS 84
+Altitude is in Feet
+Reserved 3
+0,2,255,My Track,0,0,2,8421376
+0
+39.9005,116.3905,0,80,39873.3361111111,2009-03-01,08:04:00
+39.9105,116.3905,0,80,39873.3368055556,2009-03-01,08:05:00
+39.9205,116.3905,0,80,39873.3375000000,2009-03-01,08:06:00
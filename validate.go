@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spacycoder/db_mysql/pkg/activity"
+	"github.com/spacycoder/db_mysql/pkg/geolife"
+	"github.com/spacycoder/db_mysql/pkg/trackpoint"
+)
+
+// UserAudit is what validation found in the folder of one user.
+type UserAudit struct {
+	User              string
+	Files             int
+	OverLimit         int
+	MalformedRows     int
+	NonMonotonic      int
+	OverlappingLabels int
+	UncoveredLabels   int
+	Problems          []string
+}
+
+func (a *UserAudit) problem(format string, args ...interface{}) {
+	a.Problems = append(a.Problems, fmt.Sprintf(format, args...))
+}
+
+// validateDataset parses the dataset with the same rules as the loader without
+// touching the database and prints what it found.
+func validateDataset(config *Config) error {
+	fmt.Println("Validating dataset")
+	startTime := time.Now()
+
+	labeledUsers, err := readLabeledUsers(config)
+	if err != nil {
+		return err
+	}
+
+	infos, err := ioutil.ReadDir(config.datasetPath("Data"))
+	if err != nil {
+		return err
+	}
+
+	folders := make(map[string]bool)
+	for _, info := range infos {
+		if info.IsDir() {
+			folders[info.Name()] = true
+		}
+	}
+
+	usersChan := make(chan string, config.WorkerCount)
+	audits := make(chan UserAudit, config.WorkerCount)
+	var wg sync.WaitGroup
+	for i := 0; i < config.WorkerCount; i++ {
+		wg.Add(1)
+		go func() {
+			for userID := range usersChan {
+				_, hasLabels := labeledUsers[userID]
+				audits <- auditUser(config, userID, hasLabels)
+			}
+			wg.Done()
+		}()
+	}
+	go func() {
+		for userID := range folders {
+			usersChan <- userID
+		}
+		close(usersChan)
+		wg.Wait()
+		close(audits)
+	}()
+
+	var results []UserAudit
+	for a := range audits {
+		results = append(results, a)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].User < results[j].User
+	})
+
+	var missing []string
+	for userID := range labeledUsers {
+		if !folders[userID] {
+			missing = append(missing, userID)
+		}
+	}
+	sort.Strings(missing)
+
+	printAudit(results, missing)
+	fmt.Printf("Finished in %s\n", time.Since(startTime))
+	return nil
+}
+
+// auditUser checks the trajectories and labels of one user. Trackpoints of files
+// that the size policy would not load do not cover any label.
+func auditUser(config *Config, userID string, hasLabels bool) UserAudit {
+	audit := UserAudit{User: userID}
+
+	var labels []activity.Activity
+	if hasLabels {
+		var err error
+		labels, err = auditLabels(config, userID, &audit)
+		if err != nil {
+			audit.problem("labels.txt: %v", err)
+		}
+	}
+	index := activity.NewIndex(labels)
+	covered := make(map[int]bool)
+
+	dir := config.datasetPath(fmt.Sprintf("Data/%s/Trajectory", userID))
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		audit.problem("%v", err)
+		return audit
+	}
+
+	for _, info := range infos {
+		path := fmt.Sprintf("Data/%s/Trajectory/%s", userID, info.Name())
+		points, err := auditTrajectory(config, path, &audit)
+		if err != nil {
+			audit.problem("%s: %v", info.Name(), err)
+			continue
+		}
+
+		if len(points) == 0 {
+			continue
+		}
+		if config.MatchMode == matchStrict {
+			if a, _ := index.Exact(points[0].DateTime, points[len(points)-1].DateTime); a != nil {
+				covered[a.ID] = true
+			}
+			continue
+		}
+		for _, p := range points {
+			index.EachCovering(p.DateTime, func(a *activity.Activity) {
+				covered[a.ID] = true
+			})
+		}
+	}
+
+	audit.UncoveredLabels = len(labels) - len(covered)
+	return audit
+}
+
+// auditLabels reads the labels of a user and counts malformed rows and labels
+// that overlap an earlier one. The returned labels have their line number as id.
+func auditLabels(config *Config, userID string, audit *UserAudit) ([]activity.Activity, error) {
+	f, err := os.Open(config.datasetPath(fmt.Sprintf("Data/%s/labels.txt", userID)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var labels []activity.Activity
+	reader := geolife.NewLabelReader(f)
+	for {
+		a, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *geolife.ParseError
+		if errors.As(err, &parseErr) {
+			audit.MalformedRows++
+			continue
+		}
+		if err != nil {
+			return labels, err
+		}
+		a.ID = reader.Line()
+		labels = append(labels, a)
+	}
+
+	sorted := make([]activity.Activity, len(labels))
+	copy(sorted, labels)
+	sort.Stable(activity.SortByDate(sorted))
+	var maxEnd time.Time
+	for i, a := range sorted {
+		if i > 0 && a.StartDateTime.Before(maxEnd) {
+			audit.OverlappingLabels++
+		}
+		if a.EndDateTime.After(maxEnd) {
+			maxEnd = a.EndDateTime
+		}
+	}
+	return labels, nil
+}
+
+// auditTrajectory reads one .plt file, counts it and its malformed rows and
+// timestamps that go back in time, and returns the trackpoints the loader would
+// insert under the size policy.
+func auditTrajectory(config *Config, path string, audit *UserAudit) ([]trackpoint.Trackpoint, error) {
+	f, err := os.Open(config.datasetPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var points []trackpoint.Trackpoint
+	var prev time.Time
+	reader := geolife.NewTrajectoryReader(f)
+	for {
+		t, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *geolife.ParseError
+		if errors.As(err, &parseErr) {
+			audit.MalformedRows++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !prev.IsZero() && t.DateTime.Before(prev) {
+			audit.NonMonotonic++
+		}
+		prev = t.DateTime
+		points = append(points, t)
+	}
+	audit.Files++
+
+	if len(points) > config.MaxPoints {
+		audit.OverLimit++
+		switch config.SizePolicy {
+		case policySkip:
+			return nil, nil
+		case policyTruncate:
+			return points[:config.MaxPoints], nil
+		}
+	}
+	return points, nil
+}
+
+func printAudit(audits []UserAudit, missing []string) {
+	var total UserAudit
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"User ID", "Files", "Over limit", "Malformed rows", "Non-monotonic", "Overlapping labels", "Uncovered labels"})
+	for _, a := range audits {
+		table.Append([]string{
+			a.User,
+			strconv.Itoa(a.Files),
+			strconv.Itoa(a.OverLimit),
+			strconv.Itoa(a.MalformedRows),
+			strconv.Itoa(a.NonMonotonic),
+			strconv.Itoa(a.OverlappingLabels),
+			strconv.Itoa(a.UncoveredLabels),
+		})
+		total.Files += a.Files
+		total.OverLimit += a.OverLimit
+		total.MalformedRows += a.MalformedRows
+		total.NonMonotonic += a.NonMonotonic
+		total.OverlappingLabels += a.OverlappingLabels
+		total.UncoveredLabels += a.UncoveredLabels
+	}
+	table.SetFooter([]string{
+		"Total",
+		strconv.Itoa(total.Files),
+		strconv.Itoa(total.OverLimit),
+		strconv.Itoa(total.MalformedRows),
+		strconv.Itoa(total.NonMonotonic),
+		strconv.Itoa(total.OverlappingLabels),
+		strconv.Itoa(total.UncoveredLabels),
+	})
+	table.Render()
+
+	for _, a := range audits {
+		for _, p := range a.Problems {
+			fmt.Printf("User %s: %s\n", a.User, p)
+		}
+	}
+
+	if len(missing) > 0 {
+		fmt.Printf("Users in labeled_ids.txt without a folder: %s\n", strings.Join(missing, ", "))
+	}
+}